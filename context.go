@@ -0,0 +1,49 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type loaderContextKey struct{}
+
+var errJoinedTxOptions = errors.New("cannot specify transaction options when joining an existing transaction")
+
+// Store a loader in the context, to later be resolved with LoaderFromContext
+// RunInTransaction stores its PgxTxLoader this way, so the context passed to the transaction func carries it
+func WithLoader(ctx context.Context, l QueryLoader) context.Context {
+	return context.WithValue(ctx, loaderContextKey{}, l)
+}
+
+// Resolve the loader stored in the context, or fallback if there isn't one
+// If the context holds a PgxTxLoader, the returned loader joins that transaction,
+// and transactions started from it become savepoints within it
+func LoaderFromContext(ctx context.Context, fallback PgxLoader) PgxLoader {
+
+	switch l := ctx.Value(loaderContextKey{}).(type) {
+	case PgxTxLoader:
+		return &joinedTxLoader{PgxTxLoader: l}
+	case PgxLoader:
+		return l
+	}
+
+	return fallback
+}
+
+// Exposes an existing transaction as a PgxLoader
+type joinedTxLoader struct {
+	PgxTxLoader
+}
+
+// Begin a pseudo nested transaction (savepoint) within the joined transaction
+// Transaction options can't be changed once a transaction has started, so only empty options are accepted
+func (j *joinedTxLoader) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+
+	if txOptions != (pgx.TxOptions{}) {
+		return nil, errJoinedTxOptions
+	}
+
+	return j.PgxTxLoader.Begin(ctx)
+}
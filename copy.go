@@ -0,0 +1,212 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// A loader able to COPY FROM, implemented by the loaders this package creates and PgxTxLoader
+// Loaders are checked for it with a type assertion, so PgxLoader implementations needn't support COPY
+type CopyLoader interface {
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+
+	// The common loader funcs
+	CommonLoader
+}
+
+// COPY FROM on the connection conn wraps, which must support it (e.g. *pgx.Conn, *pgxpool.Pool)
+func copyFromConn(ctx context.Context, conn interface{}, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+
+	copier, ok := conn.(interface {
+		CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	})
+	if !ok {
+		return 0, fmt.Errorf("connection %T doesn't support COPY FROM", conn)
+	}
+
+	return copier.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+// Iterates structs to copy, Struct returns the current struct after Next returns true
+type StructIterator interface {
+	Next() bool
+	Struct() interface{}
+	Err() error
+}
+
+// Bulk insert structs into table using COPY FROM, returning the number of rows copied
+// rows must be a slice of structs (or pointers to structs), or a StructIterator
+// Columns are derived from the first struct as with StructInsert, honoring the pgxload struct tags.
+// COPY can't use DEFAULT, so columns which would be DEFAULT in the first struct are left out,
+// and every struct must produce the same columns as the first. loader must implement CopyLoader
+func CopyStructs(ctx context.Context, loader CommonLoader, table string, rows interface{}) (int64, error) {
+
+	copier, ok := loader.(CopyLoader)
+	if !ok {
+		return 0, fmt.Errorf("loader %T doesn't support COPY FROM", loader)
+	}
+
+	iter, err := newStructIterator(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	if !iter.Next() {
+		return 0, iter.Err()
+	}
+
+	first := iter.Struct()
+
+	extractor, err := NewStructColumnValueExtractor(loader.Mapper(), first)
+	if err != nil {
+		return 0, err
+	}
+
+	extracted, err := extractor.Extract(first)
+	if err != nil {
+		return 0, err
+	}
+
+	src := &structCopySource{
+		iter:      iter,
+		extractor: extractor,
+		columns:   copyColumns(extracted),
+		pending:   first,
+	}
+
+	if len(src.columns) == 0 {
+		return 0, errors.New("no columns to copy")
+	}
+
+	return copier.CopyFrom(ctx, copyIdentifier(table), src.columns, src)
+}
+
+// Split a possibly schema qualified table name into an identifier
+func copyIdentifier(table string) pgx.Identifier {
+	return pgx.Identifier(strings.Split(table, "."))
+}
+
+// Extracted columns which can be copied, i.e. any not using DEFAULT
+func copyColumns(extracted ExtractedColumnValues) []string {
+
+	var columns []string
+	for _, column := range extracted.Columns() {
+		val, _ := extracted.ColumnValue(column)
+		if !val.UseDefault() {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+// pgx.CopyFromSource over a StructIterator
+type structCopySource struct {
+	iter      StructIterator
+	extractor StructColumnValueExtractor
+	columns   []string
+
+	pending interface{}
+	current interface{}
+	row     int
+	err     error
+}
+
+func (s *structCopySource) Next() bool {
+
+	if s.err != nil {
+		return false
+	}
+
+	if s.pending != nil {
+		s.current, s.pending = s.pending, nil
+		return true
+	}
+
+	if !s.iter.Next() {
+		return false
+	}
+
+	s.current = s.iter.Struct()
+	s.row += 1
+
+	return true
+}
+
+func (s *structCopySource) Values() ([]interface{}, error) {
+
+	extracted, err := s.extractor.Extract(s.current)
+	if err != nil {
+		s.err = err
+		return nil, err
+	}
+
+	columns := copyColumns(extracted)
+	if len(columns) != len(s.columns) {
+		s.err = fmt.Errorf("row %d: columns %v differ from the first row's columns %v", s.row, columns, s.columns)
+		return nil, s.err
+	}
+
+	values := make([]interface{}, len(columns))
+	for idx, column := range columns {
+		if column != s.columns[idx] {
+			s.err = fmt.Errorf("row %d: columns %v differ from the first row's columns %v", s.row, columns, s.columns)
+			return nil, s.err
+		}
+
+		val, _ := extracted.ColumnValue(column)
+		values[idx] = val.Value()
+	}
+
+	return values, nil
+}
+
+func (s *structCopySource) Err() error {
+
+	if s.err != nil {
+		return s.err
+	}
+
+	return s.iter.Err()
+}
+
+func newStructIterator(rows interface{}) (StructIterator, error) {
+
+	if iter, ok := rows.(StructIterator); ok {
+		return iter, nil
+	}
+
+	val := reflect.ValueOf(rows)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	if val.Kind() != reflect.Slice {
+		return nil, errors.New("rows to copy must be a slice or a StructIterator")
+	}
+
+	return &sliceStructIterator{slice: val, idx: -1}, nil
+}
+
+type sliceStructIterator struct {
+	slice reflect.Value
+	idx   int
+}
+
+func (s *sliceStructIterator) Next() bool {
+	s.idx += 1
+	return s.idx < s.slice.Len()
+}
+
+func (s *sliceStructIterator) Struct() interface{} {
+	return s.slice.Index(s.idx).Interface()
+}
+
+func (s *sliceStructIterator) Err() error {
+	return nil
+}
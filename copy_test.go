@@ -0,0 +1,159 @@
+package pgxload
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+type copyRow struct {
+	ID       int64 `pgxload:"defaultZero"`
+	Name     string
+	Internal string `pgxload:"omit"`
+	Nickname string `pgxload:"nullZero"`
+}
+
+func TestCopyStructs(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	conn.ExpectCopyFrom("public.users", "name", "nickname")
+
+	loader, _ := NewPgxLoader(conn)
+
+	copied, err := CopyStructs(ctx, loader, "public.users", []*copyRow{
+		{Name: "a", Internal: "x"},
+		{Name: "b", Nickname: "bee"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), copied)
+	assert.Equal(t, [][]interface{}{{"a", nil}, {"b", "bee"}}, conn.CopiedRows())
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	conn.ExpectCopyFrom("users")
+	_, err = CopyStructs(ctx, loader, "users", []copyRow{{Name: "a"}, {ID: 5, Name: "b"}})
+	assert.Error(t, err)
+}
+
+func TestCopyStructs_UnsupportedConn(t *testing.T) {
+
+	var calls []string
+	loader, _ := NewPgxLoader(routedConn{calls: &calls, name: "primary"})
+
+	_, err := CopyStructs(context.Background(), loader, "users", []copyRow{{Name: "a"}})
+	assert.Error(t, err)
+
+	// Loaders without CopyFrom are rejected rather than required by the PgxLoader interface
+	_, err = CopyStructs(context.Background(), struct{ CommonLoader }{loader}, "users", []copyRow{{Name: "a"}})
+	assert.Error(t, err)
+}
+
+func TestDecodeCopyText(t *testing.T) {
+
+	var rows [][][]byte
+	err := decodeCopyText(strings.NewReader("1\ta\\tb\t\\N\n2\tline\\nbreak\t\\\\N\n3\t\\101\t"), func(values [][]byte) error {
+		rows = append(rows, values)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, [][][]byte{
+		{[]byte("1"), []byte("a\tb"), nil},
+		{[]byte("2"), []byte("line\nbreak"), []byte(`\N`)},
+		{[]byte("3"), []byte("A"), {}},
+	}, rows)
+
+	_, err = CopyTo(context.Background(), &pgxLoader{PGXConn: pgxloadtest.NewConn()}, ioutil.Discard, CopyCSV, "select 1")
+	assert.Error(t, err)
+}
+
+type copyToRow struct {
+	ID       int64
+	Name     string
+	Nickname *string
+}
+
+func TestCopyTo(t *testing.T) {
+
+	ctx := context.Background()
+
+	server, err := pgxloadtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Close()
+
+	server.Handle("select id, name, nickname from users").
+		WillReturnRows(pgxloadtest.NewRows("id", "name", "nickname").WithTypes("int8", "text", "text").
+			AddRow(1, "a\tb", nil).
+			AddRow(2, "c, d", "dee"))
+
+	conn, err := pgx.Connect(ctx, server.ConnString())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close(ctx)
+
+	loader, _ := NewPgxLoader(conn)
+
+	var text bytes.Buffer
+	copied, err := CopyTo(ctx, loader, &text, CopyText, "select id, name, nickname from users;")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), copied)
+		assert.Equal(t, "1\ta\\tb\t\\N\n2\tc, d\tdee\n", text.String())
+	}
+
+	var csv bytes.Buffer
+	_, err = CopyTo(ctx, loader, &csv, CopyCSV, "select id, name, nickname from users")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "id,name,nickname\n1,a\tb,\n2,\"c, d\",dee\n", csv.String())
+	}
+
+	assert.Equal(t, "COPY (select id, name, nickname from users) TO STDOUT", server.Statements()[0].SQL)
+}
+
+func TestCopyToStructs(t *testing.T) {
+
+	ctx := context.Background()
+
+	server, err := pgxloadtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Close()
+
+	server.Handle("select id, name, nickname from users").
+		WillReturnRows(pgxloadtest.NewRows("id", "name", "nickname").WithTypes("int8", "text", "text").
+			AddRow(1, "a\tb", nil).
+			AddRow(2, "c", "dee"))
+
+	pool, err := pgxpool.Connect(ctx, server.ConnString())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pool.Close()
+
+	loader, _ := NewPgxLoader(pool)
+
+	var rows []*copyToRow
+	if !assert.NoError(t, CopyToStructs(ctx, loader, &rows, "select id, name, nickname from users")) {
+		return
+	}
+
+	nickname := "dee"
+	assert.Equal(t, []*copyToRow{
+		{ID: 1, Name: "a\tb"},
+		{ID: 2, Name: "c", Nickname: &nickname},
+	}, rows)
+
+	// The acquired connection is released back to the pool
+	assert.Equal(t, int32(0), pool.Stat().AcquiredConns())
+}
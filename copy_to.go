@@ -0,0 +1,265 @@
+package pgxload
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Format of COPY TO output
+type CopyFormat string
+
+const (
+	// Tab separated, with \N for NULL and backslash escapes
+	CopyText CopyFormat = "text"
+
+	// Comma separated, with a header row
+	CopyCSV CopyFormat = "csv"
+)
+
+// Implemented by loaders and connections wrapping another connection
+// ctx is the context of the COPY TO, so loaders can route it like a read
+type connUnwrapper interface {
+	unwrapConn(ctx context.Context) interface{}
+}
+
+// Find the *pgx.Conn underneath a loader, needed for COPY TO
+// A connection is acquired from a *pgxpool.Pool, call release once done with the connection to return it
+func pgxConnOf(ctx context.Context, l interface{}) (conn *pgx.Conn, release func(), err error) {
+
+	for {
+		switch c := l.(type) {
+		case *pgx.Conn:
+			return c, func() {}, nil
+		case *pgxpool.Pool:
+			poolConn, err := c.Acquire(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			return poolConn.Conn(), poolConn.Release, nil
+		case interface{ Conn() *pgx.Conn }:
+			if conn := c.Conn(); conn != nil {
+				return conn, func() {}, nil
+			}
+			return nil, nil, errors.New("loader has no underlying *pgx.Conn")
+		case connUnwrapper:
+			l = c.unwrapConn(ctx)
+		default:
+			return nil, nil, errors.New("COPY TO requires a loader using a *pgx.Conn, *pgxpool.Pool, *pgxpool.Conn or transaction")
+		}
+	}
+}
+
+func copyToSQL(query string, format CopyFormat) string {
+
+	stmt := "COPY (" + strings.TrimSuffix(strings.TrimSpace(query), ";") + ") TO STDOUT"
+
+	if format == CopyCSV {
+		stmt += " WITH (FORMAT csv, HEADER true)"
+	}
+
+	return stmt
+}
+
+// Run COPY (query) TO STDOUT, streaming the output in format directly into w
+// COPY can't take parameters, so any values in query must be literals (see QuoteLiteral).
+// Replica loaders run it on a replica, like Query, unless the context is WithPrimaryReads
+// Returns the number of rows copied
+func CopyTo(ctx context.Context, loader QueryLoader, w io.Writer, format CopyFormat, query string) (int64, error) {
+
+	conn, release, err := pgxConnOf(ctx, loader)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	tag, err := conn.PgConn().CopyTo(ctx, w, copyToSQL(query, format))
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Run COPY (query) TO STDOUT and decode the output into dest, a pointer to a slice of structs
+// Columns are mapped to fields using the loader's mapper, and values are decoded as pgx would decode query results.
+// Rows are decoded as they stream in, avoiding the per row protocol overhead of Query for large extracts
+func CopyToStructs(ctx context.Context, loader QueryLoader, dest interface{}, query string) error {
+
+	val, err := prepareInput(dest)
+	if err != nil {
+		return err
+	}
+
+	if val.Kind() != reflect.Slice {
+		return errors.New("copy destination must be a pointer to a slice")
+	}
+
+	conn, release, err := pgxConnOf(ctx, loader)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Describe the query first, for the column names and types needed to decode the text output
+	sd, err := conn.PgConn().Prepare(ctx, "", query, nil)
+	if err != nil {
+		return err
+	}
+
+	fields := sd.Fields
+	for idx := range fields {
+		fields[idx].Format = pgx.TextFormatCode
+	}
+
+	columns := make([]string, len(fields))
+	for idx, fd := range fields {
+		columns[idx] = string(fd.Name)
+	}
+
+	elemType := SliceElemType(val)
+	traversals := loader.Mapper().TraversalsByName(elemType, columns)
+	if err := missingColumns(columns, traversals); err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	copyErr := make(chan error, 1)
+
+	go func() {
+		_, err := conn.PgConn().CopyTo(ctx, pw, copyToSQL(query, CopyText))
+		_ = pw.CloseWithError(err)
+		copyErr <- err
+	}()
+
+	decodeErr := decodeCopyText(pr, func(values [][]byte) error {
+		elem := reflect.New(elemType)
+
+		ptrs := make([]interface{}, len(columns))
+		if err := fieldsByTraversal(elem, traversals, ptrs, true); err != nil {
+			return err
+		}
+
+		if err := pgx.ScanRow(conn.ConnInfo(), fields, values, ptrs...); err != nil {
+			return err
+		}
+
+		ReflectAppend(val, elem)
+		return nil
+	})
+
+	// Drain anything left after a decode failure, so the connection stays usable
+	_, _ = io.Copy(ioutil.Discard, pr)
+
+	if err := <-copyErr; err != nil {
+		return err
+	}
+
+	return decodeErr
+}
+
+// Decode COPY text format rows from r, calling fn with the raw text values of each row (nil for NULL)
+func decodeCopyText(r io.Reader, fn func(values [][]byte) error) error {
+
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if line[len(line)-1] == '\n' {
+				line = line[:len(line)-1]
+			}
+
+			if fnErr := fn(splitCopyTextRow(line)); fnErr != nil {
+				return fnErr
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Split a single COPY text format row into its unescaped values
+func splitCopyTextRow(line []byte) [][]byte {
+
+	var values [][]byte
+	var current []byte
+
+	finish := func() {
+		if string(current) == `\N` {
+			values = append(values, nil)
+		} else {
+			values = append(values, unescapeCopyText(current))
+		}
+		current = []byte{}
+	}
+
+	current = []byte{}
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) {
+			current = append(current, line[i], line[i+1])
+			i++
+			continue
+		}
+
+		if line[i] == '\t' {
+			finish()
+			continue
+		}
+
+		current = append(current, line[i])
+	}
+
+	finish()
+
+	return values
+}
+
+var copyTextEscapes = map[byte]byte{
+	'b': '\b',
+	'f': '\f',
+	'n': '\n',
+	'r': '\r',
+	't': '\t',
+	'v': '\v',
+}
+
+func unescapeCopyText(value []byte) []byte {
+
+	unescaped := make([]byte, 0, len(value))
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 >= len(value) {
+			unescaped = append(unescaped, value[i])
+			continue
+		}
+
+		i++
+		if replacement, ok := copyTextEscapes[value[i]]; ok {
+			unescaped = append(unescaped, replacement)
+		} else if value[i] >= '0' && value[i] <= '7' {
+			octal := 0
+			for n := 0; n < 3 && i < len(value) && value[i] >= '0' && value[i] <= '7'; n++ {
+				octal = octal*8 + int(value[i]-'0')
+				i++
+			}
+			i--
+			unescaped = append(unescaped, byte(octal))
+		} else {
+			unescaped = append(unescaped, value[i])
+		}
+	}
+
+	return unescaped
+}
@@ -0,0 +1,146 @@
+package pgxload
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+func NewStructDelete(tableName string, data ...interface{}) StructDelete {
+	return StructDelete{
+		tableName:    tableName,
+		data:         data,
+		matchColumns: nil,
+		returning:    "",
+	}
+}
+
+type StructDelete struct {
+	tableName    string
+	data         []interface{}
+	matchColumns []string
+	returning    string
+}
+
+// Match rows by cols instead of the pk tagged fields
+func (del StructDelete) WithMatchColumns(cols ...string) StructDelete {
+	return StructDelete{
+		tableName:    del.tableName,
+		data:         del.data,
+		matchColumns: cols,
+		returning:    del.returning,
+	}
+}
+
+func (del StructDelete) WithReturning(str string) StructDelete {
+	return StructDelete{
+		tableName:    del.tableName,
+		data:         del.data,
+		matchColumns: del.matchColumns,
+		returning:    str,
+	}
+}
+
+func (del StructDelete) WithReturningColumns(cols ...string) StructDelete {
+	return del.WithReturning(ToColumnList(cols...))
+}
+
+// Generate a delete of every struct's row, matched by the pk tagged fields or WithMatchColumns
+// A single key column is matched with = ANY($1) so any number of rows share one statement, the array type is
+// inferred from the column unless set with the type tag option. Falls back to IN when the column's type can't be determined.
+// Composite keys use a tuple IN
+func (del StructDelete) GenerateDelete(m *reflectx.Mapper) (string, []interface{}, error) {
+
+	if len(del.data) == 0 {
+		return "", nil, errors.New("missing input to delete")
+	}
+
+	extractor, err := NewStructColumnValueExtractor(m, del.data[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	columns := del.matchColumns
+	if len(columns) == 0 {
+		columns = extractor.PrimaryKeyColumns()
+	}
+
+	if len(columns) == 0 {
+		return "", nil, errors.New("missing columns to match, tag fields with pk or use WithMatchColumns")
+	}
+
+	keys := make([][]interface{}, len(del.data))
+	for idx, dat := range del.data {
+		keys[idx] = make([]interface{}, len(columns))
+
+		for colIdx, column := range columns {
+			keys[idx][colIdx], err = extractor.ExtractRawColumnData(dat, column)
+			if err != nil {
+				return "", nil, err
+			}
+
+			if isNilValue(keys[idx][colIdx]) {
+				return "", nil, fmt.Errorf("row %d: missing value for column to match %s", idx, column)
+			}
+		}
+	}
+
+	var where string
+	var params []interface{}
+
+	if len(keys) == 1 {
+		where, params, _ = matchSyntax(columns, keys[0], 0)
+	} else if len(columns) == 1 {
+		where, params = del.anySyntax(extractor, columns[0], keys)
+	} else {
+		tuples := make([]string, len(keys))
+		for idx, key := range keys {
+			placeholders := make([]string, len(key))
+			for colIdx := range key {
+				params = append(params, key[colIdx])
+				placeholders[colIdx] = fmt.Sprintf("$%d", len(params))
+			}
+
+			tuples[idx] = "(" + strings.Join(placeholders, ", ") + ")"
+		}
+
+		where = "(" + ToColumnList(columns...) + ") IN (" + strings.Join(tuples, ", ") + ")"
+	}
+
+	stmt := "DELETE FROM " + del.tableName + " WHERE " + where
+
+	if len(del.returning) > 0 {
+		stmt += " RETURNING " + del.returning
+	}
+
+	return stmt, params, nil
+}
+
+// Match a single column against every key
+// The array is only cast when the type tag option is set, so Postgres infers e.g. uuid or enum arrays from the column
+func (del StructDelete) anySyntax(extractor StructColumnValueExtractor, column string, keys [][]interface{}) (string, []interface{}) {
+
+	values := make([]interface{}, len(keys))
+	for idx, key := range keys {
+		values[idx] = key[0]
+	}
+
+	if typeName, err := extractor.columnPgType(column); err == nil {
+		array := []interface{}{&valueArray{typeName: typeName, values: values}}
+
+		if extractor.columnTagPgType(column) != "" {
+			return fmt.Sprintf("%s = ANY($1::%s[])", QuotedColumn(column), typeName), array
+		}
+
+		return QuotedColumn(column) + " = ANY($1)", array
+	}
+
+	placeholders := make([]string, len(values))
+	for idx := range values {
+		placeholders[idx] = fmt.Sprintf("$%d", idx+1)
+	}
+
+	return QuotedColumn(column) + " IN (" + strings.Join(placeholders, ", ") + ")", values
+}
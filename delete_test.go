@@ -0,0 +1,56 @@
+package pgxload
+
+import (
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+type deleteRow struct {
+	ID   int64 `pgxload:"pk"`
+	Name string
+}
+
+type uuidDeleteRow struct {
+	ID string `pgxload:"pk,type=uuid"`
+}
+
+func TestStructDelete_GenerateDelete(t *testing.T) {
+
+	m := DefaultConfig.generateMapper()
+
+	stmt, params, err := NewStructDelete("people", &deleteRow{ID: 1}).WithReturningColumns("name").GenerateDelete(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `DELETE FROM people WHERE "id" = $1 RETURNING "name"`, stmt)
+		assert.Equal(t, []interface{}{int64(1)}, params)
+	}
+
+	stmt, params, err = NewStructDelete("people", &deleteRow{ID: 1}, &deleteRow{ID: 2}).GenerateDelete(m)
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(params)) {
+		assert.Equal(t, `DELETE FROM people WHERE "id" = ANY($1)`, stmt)
+
+		buf, err := params[0].(pgtype.TextEncoder).EncodeText(pgtype.NewConnInfo(), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "{1,2}", string(buf))
+	}
+
+	stmt, params, err = NewStructDelete("memberships", &membership{OrgID: 1, UserID: 2}, &membership{OrgID: 1, UserID: 3}).GenerateDelete(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `DELETE FROM memberships WHERE ("org_id", "user_id") IN (($1, $2), ($3, $4))`, stmt)
+		assert.Equal(t, []interface{}{int64(1), int64(2), int64(1), int64(3)}, params)
+	}
+
+	stmt, _, err = NewStructDelete("people", &deleteRow{ID: 1, Name: "a"}).WithMatchColumns("name").GenerateDelete(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `DELETE FROM people WHERE "name" = $1`, stmt)
+	}
+
+	stmt, _, err = NewStructDelete("people", &uuidDeleteRow{ID: "a"}, &uuidDeleteRow{ID: "b"}).GenerateDelete(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `DELETE FROM people WHERE "id" = ANY($1::uuid[])`, stmt)
+	}
+
+	_, _, err = NewStructDelete("people", &upsertRow{}).GenerateDelete(m)
+	assert.Error(t, err)
+}
@@ -12,6 +12,9 @@ func NewStructInsert(tableName string, data ...interface{}) StructInsert {
 		data:         data,
 		returning:    "",
 		conflictStmt: "",
+		conflict:     nil,
+		insertedFlag: false,
+		duplicates:   DuplicateKeysAllow,
 	}
 }
 
@@ -20,6 +23,9 @@ type StructInsert struct {
 	data         []interface{}
 	returning    string
 	conflictStmt string
+	conflict     *conflictClause
+	insertedFlag bool
+	duplicates   DuplicateKeyPolicy
 }
 
 func (ins StructInsert) WithConflict(str string) StructInsert {
@@ -28,6 +34,9 @@ func (ins StructInsert) WithConflict(str string) StructInsert {
 		data:         ins.data,
 		returning:    ins.returning,
 		conflictStmt: str,
+		conflict:     nil,
+		insertedFlag: ins.insertedFlag,
+		duplicates:   ins.duplicates,
 	}
 }
 
@@ -37,6 +46,22 @@ func (ins StructInsert) WithReturning(str string) StructInsert {
 		data:         ins.data,
 		returning:    str,
 		conflictStmt: ins.conflictStmt,
+		conflict:     ins.conflict,
+		insertedFlag: ins.insertedFlag,
+		duplicates:   ins.duplicates,
+	}
+}
+
+// Copy of this insert for a different set of data
+func (ins StructInsert) withData(data []interface{}) StructInsert {
+	return StructInsert{
+		tableName:    ins.tableName,
+		data:         data,
+		returning:    ins.returning,
+		conflictStmt: ins.conflictStmt,
+		conflict:     ins.conflict,
+		insertedFlag: ins.insertedFlag,
+		duplicates:   ins.duplicates,
 	}
 }
 
@@ -50,6 +75,11 @@ func (ins StructInsert) GenerateInsert(m *reflectx.Mapper) (string, []interface{
 		return "", nil, errors.New("missing input to insert")
 	}
 
+	ins, err := ins.resolveDuplicates(m)
+	if err != nil {
+		return "", nil, err
+	}
+
 	stmt := "INSERT INTO " + ins.tableName + " "
 	var params []interface{}
 	var paramOffset int
@@ -59,38 +89,48 @@ func (ins StructInsert) GenerateInsert(m *reflectx.Mapper) (string, []interface{
 		return "", nil, err
 	}
 
+	rows := make([]ExtractedColumnValues, len(ins.data))
 	for idx, dat := range ins.data {
-		extracted, err := extractor.Extract(dat)
+		rows[idx], err = extractor.Extract(dat)
 		if err != nil {
 			return "", nil, err
 		}
+	}
+
+	// Rows may omit different columns, so insert the union of them all and DEFAULT anything a row is missing
+	columns := extractor.unionColumns(rows)
+	if len(columns) == 0 {
+		return "", nil, errors.New("no columns to insert")
+	}
+
+	for idx, row := range rows {
+		row = row.withColumns(columns)
 
 		if idx == 0 {
-			stmt += extracted.InsertColumnSyntax() + " VALUES "
+			stmt += row.InsertColumnSyntax() + " VALUES "
 		}
 
 		var toAdd string
 		var toAddParams []interface{}
-		toAdd, toAddParams, paramOffset = extracted.InsertValueSyntax(paramOffset)
-
-		paramOffset += 1
+		toAdd, toAddParams, paramOffset = row.InsertValueSyntax(paramOffset)
 
 		stmt += toAdd
 		params = append(params, toAddParams...)
 
-		if idx < len(ins.data)-1 {
+		if idx < len(rows)-1 {
 			stmt += ", "
 		}
 
 	}
 
-	if len(ins.conflictStmt) > 0 {
-		stmt += " ON CONFLICT " + ins.conflictStmt
+	conflict, err := ins.conflictSyntax(insertedColumns(columns, rows))
+	if err != nil {
+		return "", nil, err
 	}
 
-	if len(ins.returning) > 0 {
-		stmt += " RETURNING " + ins.returning
-	}
+	stmt += conflict
+
+	stmt += ins.returningSyntax()
 
 	return stmt, params, nil
 }
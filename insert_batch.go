@@ -0,0 +1,136 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// The maximum number of bind parameters Postgres accepts in a single statement
+const MaxBindParameters = 65535
+
+// A single generated insert statement and its parameters
+type InsertBatch struct {
+	SQL    string
+	Params []interface{}
+}
+
+// Generate the insert as multiple statements, each using at most maxParams bind parameters
+// Rows are kept in order, and each statement uses the same conflict and returning clauses.
+// maxParams <= 0 uses MaxBindParameters
+func (ins StructInsert) GenerateInsertBatches(m *reflectx.Mapper, maxParams int) ([]InsertBatch, error) {
+
+	if len(ins.data) == 0 {
+		return nil, errors.New("missing input to insert")
+	}
+
+	// Resolve duplicates across the whole insert, not just within each batch
+	ins, err := ins.resolveDuplicates(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxParams <= 0 {
+		maxParams = MaxBindParameters
+	}
+
+	extractor, err := NewStructColumnValueExtractor(m, ins.data[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var batches []InsertBatch
+	var chunk []interface{}
+	var chunkParams int
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		stmt, params, err := ins.withData(chunk).GenerateInsert(m)
+		if err != nil {
+			return err
+		}
+
+		batches = append(batches, InsertBatch{SQL: stmt, Params: params})
+		chunk, chunkParams = nil, 0
+		return nil
+	}
+
+	for idx, dat := range ins.data {
+		extracted, err := extractor.Extract(dat)
+		if err != nil {
+			return nil, err
+		}
+
+		_, rowParams, _ := extracted.InsertValueSyntax(0)
+		if len(rowParams) > maxParams {
+			return nil, fmt.Errorf("row %d needs %d parameters, more than the limit of %d", idx, len(rowParams), maxParams)
+		}
+
+		if chunkParams+len(rowParams) > maxParams {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+
+		chunk = append(chunk, dat)
+		chunkParams += len(rowParams)
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return batches, nil
+}
+
+// Execute the insert in batches of at most maxParams bind parameters, all within a single transaction
+// If loader is already a transaction the batches run in a savepoint.
+// If dest is non-nil, RETURNING rows from every batch are scanned into it, so it should be a pointer to a slice.
+// Returns the total rows affected across all batches
+func ExecStructInsertBatches(ctx context.Context, loader QueryLoader, ins StructInsert, maxParams int, dest interface{}) (int64, error) {
+
+	batches, err := ins.GenerateInsertBatches(loader.Mapper(), maxParams)
+	if err != nil {
+		return 0, err
+	}
+
+	var affected int64
+	err = RunInNestedTransaction(ctx, loader, func(ctx context.Context, tx PgxTxLoader) error {
+		affected = 0
+
+		for _, batch := range batches {
+			if dest == nil {
+				tag, err := tx.Exec(ctx, batch.SQL, batch.Params...)
+				if err != nil {
+					return err
+				}
+
+				affected += tag.RowsAffected()
+				continue
+			}
+
+			rows, err := tx.Query(ctx, batch.SQL, batch.Params...)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Scanner(rows).Scan(dest); err != nil {
+				return err
+			}
+
+			affected += rows.CommandTag().RowsAffected()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return affected, nil
+}
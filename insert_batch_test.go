@@ -0,0 +1,74 @@
+package pgxload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+type batchRow struct {
+	ID   int64 `pgxload:"defaultZero"`
+	Name string
+	Age  int
+}
+
+func TestGenerateInsertBatches(t *testing.T) {
+
+	loader, _ := NewPgxLoader(pgxloadtest.NewConn())
+
+	ins := NewStructInsert("people",
+		&batchRow{Name: "a", Age: 1},
+		&batchRow{Name: "b", Age: 2},
+		&batchRow{Name: "c", Age: 3},
+	).WithReturningColumns("id")
+
+	batches, err := ins.GenerateInsertBatches(loader.Mapper(), 5)
+	if assert.NoError(t, err) && assert.Equal(t, 2, len(batches)) {
+		assert.Equal(t, `INSERT INTO people ("id", "name", "age") VALUES (DEFAULT, $1, $2), (DEFAULT, $3, $4) RETURNING "id"`, batches[0].SQL)
+		assert.Equal(t, []interface{}{"a", 1, "b", 2}, batches[0].Params)
+		assert.Equal(t, `INSERT INTO people ("id", "name", "age") VALUES (DEFAULT, $1, $2) RETURNING "id"`, batches[1].SQL)
+		assert.Equal(t, []interface{}{"c", 3}, batches[1].Params)
+	}
+
+	_, err = ins.GenerateInsertBatches(loader.Mapper(), 1)
+	assert.Error(t, err)
+}
+
+func TestExecStructInsertBatches(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	ins := NewStructInsert("people",
+		&batchRow{Name: "a", Age: 1},
+		&batchRow{Name: "b", Age: 2},
+		&batchRow{Name: "c", Age: 3},
+	).WithReturningColumns("id")
+
+	conn.ExpectBegin()
+	conn.ExpectQueryRegex(`^INSERT INTO people`).WithArgs("a", 1, "b", 2).
+		WillReturnRows(pgxloadtest.NewRows("id").AddRow(int64(1)).AddRow(int64(2)))
+	conn.ExpectQueryRegex(`^INSERT INTO people`).WithArgs("c", 3).
+		WillReturnRows(pgxloadtest.NewRows("id").AddRow(int64(3)))
+	conn.ExpectCommit()
+
+	var ids []batchRow
+	affected, err := ExecStructInsertBatches(ctx, loader, ins, 4, &ids)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+	assert.Equal(t, []batchRow{{ID: 1}, {ID: 2}, {ID: 3}}, ids)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	conn.ExpectBegin()
+	conn.ExpectExecRegex(`^INSERT INTO people`).WillReturnResult("INSERT 0 2")
+	conn.ExpectExecRegex(`^INSERT INTO people`).WillReturnError(assert.AnError)
+	conn.ExpectRollback()
+
+	_, err = ExecStructInsertBatches(ctx, loader, ins, 4, nil)
+	assert.Equal(t, assert.AnError, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
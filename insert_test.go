@@ -0,0 +1,46 @@
+package pgxload
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type paramNumberingRow struct {
+	ID   int64
+	Name string
+}
+
+func TestGenerateInsert_ParamNumbering(t *testing.T) {
+
+	stmt, params, err := NewStructInsert("people",
+		&paramNumberingRow{ID: 1, Name: "a"},
+		&paramNumberingRow{ID: 2, Name: "b"},
+		&paramNumberingRow{ID: 3, Name: "c"},
+	).GenerateInsert(DefaultConfig.generateMapper())
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, `INSERT INTO people ("id", "name") VALUES ($1, $2), ($3, $4), ($5, $6)`, stmt)
+		assert.Equal(t, []interface{}{int64(1), "a", int64(2), "b", int64(3), "c"}, params)
+	}
+}
+
+type heterogeneousRow struct {
+	ID       int64 `pgxload:"defaultZero"`
+	Name     string
+	Nickname string `pgxload:"omitZero"`
+	Age      int    `pgxload:"omitZero"`
+}
+
+func TestGenerateInsert_HeterogeneousRows(t *testing.T) {
+
+	stmt, params, err := NewStructInsert("people",
+		&heterogeneousRow{Name: "a", Age: 1},
+		&heterogeneousRow{ID: 7, Name: "b", Nickname: "bee"},
+	).GenerateInsert(DefaultConfig.generateMapper())
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, `INSERT INTO people ("id", "name", "nickname", "age") VALUES (DEFAULT, $1, DEFAULT, $2), ($3, $4, $5, DEFAULT)`, stmt)
+		assert.Equal(t, []interface{}{"a", 1, int64(7), "b", "bee"}, params)
+	}
+}
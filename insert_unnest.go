@@ -0,0 +1,253 @@
+package pgxload
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+var defaultConnInfo = pgtype.NewConnInfo()
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	bytesType   = reflect.TypeOf([]byte(nil))
+	pgValueType = reflect.TypeOf((*pgtype.Value)(nil)).Elem()
+)
+
+// Generate the insert as INSERT ... SELECT * FROM unnest($1::type[], ...), with one array parameter per column
+// The SQL only depends on the columns and their types, so every batch size reuses the same (prepared) statement.
+// Array element types are derived from the Go field types, override with the type tag option (e.g. pgxload:"type=jsonb").
+// unnest can't express DEFAULT, so columns which are DEFAULT (or omitted) for every row are left out,
+// and a column which is DEFAULT for only some rows is an error
+func (ins StructInsert) GenerateUnnestInsert(m *reflectx.Mapper) (string, []interface{}, error) {
+
+	if len(ins.data) == 0 {
+		return "", nil, errors.New("missing input to insert")
+	}
+
+	ins, err := ins.resolveDuplicates(m)
+	if err != nil {
+		return "", nil, err
+	}
+
+	extractor, err := NewStructColumnValueExtractor(m, ins.data[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	rows := make([]ExtractedColumnValues, len(ins.data))
+	for idx, dat := range ins.data {
+		rows[idx], err = extractor.Extract(dat)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	var columns []string
+	var arrays []string
+	var params []interface{}
+
+	for _, column := range extractor.unionColumns(rows) {
+		values := make([]interface{}, len(rows))
+		defaults := 0
+
+		for idx, row := range rows {
+			val, ok := row.ColumnValue(column)
+			if !ok || val.UseDefault() {
+				defaults += 1
+				continue
+			}
+
+			values[idx] = val.Value()
+		}
+
+		if defaults == len(rows) {
+			continue
+		} else if defaults > 0 {
+			return "", nil, fmt.Errorf("column %s is DEFAULT for some rows but not others, which unnest inserts can't express", column)
+		}
+
+		typeName, err := extractor.columnPgType(column)
+		if err != nil {
+			return "", nil, err
+		}
+
+		columns = append(columns, column)
+		params = append(params, &valueArray{typeName: typeName, values: values})
+		arrays = append(arrays, fmt.Sprintf("$%d::%s[]", len(params), typeName))
+	}
+
+	if len(columns) == 0 {
+		return "", nil, errors.New("no columns to insert")
+	}
+
+	stmt := "INSERT INTO " + ins.tableName + " (" + ToColumnList(columns...) + ") SELECT * FROM unnest(" + strings.Join(arrays, ", ") + ")"
+
+	conflict, err := ins.conflictSyntax(columns)
+	if err != nil {
+		return "", nil, err
+	}
+
+	stmt += conflict
+
+	stmt += ins.returningSyntax()
+
+	return stmt, params, nil
+}
+
+// Postgres type name of column, from its type tag option or Go type
+func (s StructColumnValueExtractor) columnPgType(column string) (string, error) {
+
+	for fieldNum, field := range s.structMap.Index {
+		if field.Name != column {
+			continue
+		}
+
+		if typeName := s.tagOpts(fieldNum).PgType; len(typeName) > 0 {
+			return typeName, nil
+		}
+
+		if typeName, ok := pgTypeForGoType(field.Field.Type); ok {
+			return typeName, nil
+		}
+
+		return "", fmt.Errorf("unable to determine the postgres type of column %s (%s), set it with the type tag option", column, field.Field.Type)
+	}
+
+	return "", errors.New("unknown column " + column)
+}
+
+// Postgres type name set with column's type tag option, empty if unset
+func (s StructColumnValueExtractor) columnTagPgType(column string) string {
+
+	for fieldNum, field := range s.structMap.Index {
+		if field.Name == column {
+			return s.tagOpts(fieldNum).PgType
+		}
+	}
+
+	return ""
+}
+
+func pgTypeForGoType(t reflect.Type) (string, bool) {
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if reflect.PtrTo(t).Implements(pgValueType) {
+		if dt, ok := defaultConnInfo.DataTypeForValue(reflect.New(t).Interface().(pgtype.Value)); ok {
+			return dt.Name, true
+		}
+
+		return "", false
+	}
+
+	switch {
+	case t == timeType:
+		return "timestamptz", true
+	case t == bytesType:
+		return "bytea", true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool", true
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "int2", true
+	case reflect.Int32, reflect.Uint16:
+		return "int4", true
+	case reflect.Int, reflect.Int64, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return "int8", true
+	case reflect.Float32:
+		return "float4", true
+	case reflect.Float64:
+		return "float8", true
+	case reflect.String:
+		return "text", true
+	}
+
+	return "", false
+}
+
+// A column of values sent as a text format array, so elements may be NULL
+type valueArray struct {
+	typeName string
+	values   []interface{}
+}
+
+func (a *valueArray) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+
+	buf = append(buf, '{')
+
+	for idx, value := range a.values {
+		if idx > 0 {
+			buf = append(buf, ',')
+		}
+
+		elem, err := a.encodeElement(ci, value)
+		if err != nil {
+			return nil, err
+		}
+
+		if elem == nil {
+			buf = append(buf, "NULL"...)
+		} else {
+			buf = append(buf, pgtype.QuoteArrayElementIfNeeded(string(elem))...)
+		}
+	}
+
+	return append(buf, '}'), nil
+}
+
+// Text encoding of a single element, nil for NULL
+func (a *valueArray) encodeElement(ci *pgtype.ConnInfo, value interface{}) ([]byte, error) {
+
+	if value == nil {
+		return nil, nil
+	}
+
+	if encoder, ok := value.(pgtype.TextEncoder); ok {
+		if val := reflect.ValueOf(value); val.Kind() == reflect.Ptr && val.IsNil() {
+			return nil, nil
+		}
+
+		return encoder.EncodeText(ci, []byte{})
+	}
+
+	if val := reflect.ValueOf(value); val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil, nil
+		}
+
+		value = val.Elem().Interface()
+	}
+
+	dt, ok := ci.DataTypeForName(a.typeName)
+	if !ok {
+		// Types pgx doesn't know (e.g. enums) are sent as their string representation
+		if str, ok := value.(string); ok {
+			return []byte(str), nil
+		}
+
+		return nil, fmt.Errorf("unable to encode %T as unknown type %s", value, a.typeName)
+	}
+
+	// Set a fresh copy, the registered value is shared
+	elem := reflect.New(reflect.TypeOf(dt.Value).Elem()).Interface().(pgtype.Value)
+	if err := elem.Set(value); err != nil {
+		return nil, err
+	}
+
+	encoder, ok := elem.(pgtype.TextEncoder)
+	if !ok {
+		return nil, fmt.Errorf("type %s has no text encoding", a.typeName)
+	}
+
+	return encoder.EncodeText(ci, []byte{})
+}
@@ -0,0 +1,53 @@
+package pgxload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+type unnestRow struct {
+	ID      int64 `pgxload:"defaultZero"`
+	Name    string
+	Score   *float64
+	Data    string `pgxload:"type=jsonb"`
+	Created time.Time
+	Note    pgtype.Text
+}
+
+func TestGenerateUnnestInsert(t *testing.T) {
+
+	score := 1.5
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	stmt, params, err := NewStructInsert("people",
+		&unnestRow{Name: "a", Score: &score, Data: `{"a":1}`, Created: created, Note: pgtype.Text{String: "x", Status: pgtype.Present}},
+		&unnestRow{Name: "b, \"c\"", Data: `{}`, Created: created, Note: pgtype.Text{Status: pgtype.Null}},
+	).WithReturningColumns("id").GenerateUnnestInsert(DefaultConfig.generateMapper())
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, `INSERT INTO people ("name", "score", "data", "created", "note") SELECT * FROM unnest($1::text[], $2::float8[], $3::jsonb[], $4::timestamptz[], $5::text[]) RETURNING "id"`, stmt)
+
+	var encoded []string
+	for _, param := range params {
+		buf, err := param.(pgtype.TextEncoder).EncodeText(pgtype.NewConnInfo(), nil)
+		assert.NoError(t, err)
+		encoded = append(encoded, string(buf))
+	}
+
+	assert.Equal(t, []string{
+		`{a,"b, \"c\""}`,
+		`{1.5,NULL}`,
+		`{"{\"a\":1}","{}"}`,
+		`{2020-01-02 03:04:05Z,2020-01-02 03:04:05Z}`,
+		`{x,NULL}`,
+	}, encoded)
+
+	_, _, err = NewStructInsert("people", &unnestRow{Name: "a"}, &unnestRow{ID: 1, Name: "b"}).GenerateUnnestInsert(DefaultConfig.generateMapper())
+	assert.Error(t, err)
+}
@@ -0,0 +1,206 @@
+package pgxload
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Wrap a PGXConn so every Exec, Query, QueryRow and CopyFrom is recorded in metrics
+// Transactions started from the returned connection are recorded as well
+func NewInstrumentedConn(conn PGXConn, metrics *Metrics) PGXConn {
+	return &instrumentedConn{
+		PGXConn: conn,
+		metrics: metrics,
+	}
+}
+
+type instrumentedConn struct {
+	PGXConn
+	metrics *Metrics
+}
+
+func (c *instrumentedConn) unwrapConn(ctx context.Context) interface{} {
+	return c.PGXConn
+}
+
+func (c *instrumentedConn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return instrumentCopyFrom(c.metrics, tableName, columnNames, func() (int64, error) {
+		return copyFromConn(ctx, c.PGXConn, tableName, columnNames, rowSrc)
+	})
+}
+
+func (c *instrumentedConn) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := c.PGXConn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedTx{Tx: tx, metrics: c.metrics}, nil
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := c.PGXConn.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedTx{Tx: tx, metrics: c.metrics}, nil
+}
+
+func (c *instrumentedConn) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	start := time.Now()
+	tag, err := c.PGXConn.Exec(ctx, sql, arguments...)
+	c.metrics.Observe(sql, time.Since(start), 0, tag.RowsAffected(), err)
+
+	return tag, err
+}
+
+func (c *instrumentedConn) Query(ctx context.Context, sql string, optionsAndArgs ...interface{}) (pgx.Rows, error) {
+	return instrumentQuery(c.metrics, sql, func() (pgx.Rows, error) {
+		return c.PGXConn.Query(ctx, sql, optionsAndArgs...)
+	})
+}
+
+func (c *instrumentedConn) QueryRow(ctx context.Context, sql string, optionsAndArgs ...interface{}) pgx.Row {
+	start := time.Now()
+	row := c.PGXConn.QueryRow(ctx, sql, optionsAndArgs...)
+
+	return &instrumentedRow{
+		Row:     row,
+		metrics: c.metrics,
+		sql:     sql,
+		start:   start,
+	}
+}
+
+type instrumentedTx struct {
+	pgx.Tx
+	metrics *Metrics
+}
+
+func (t *instrumentedTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := t.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedTx{Tx: tx, metrics: t.metrics}, nil
+}
+
+func (t *instrumentedTx) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	start := time.Now()
+	tag, err := t.Tx.Exec(ctx, sql, arguments...)
+	t.metrics.Observe(sql, time.Since(start), 0, tag.RowsAffected(), err)
+
+	return tag, err
+}
+
+func (t *instrumentedTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return instrumentQuery(t.metrics, sql, func() (pgx.Rows, error) {
+		return t.Tx.Query(ctx, sql, args...)
+	})
+}
+
+func (t *instrumentedTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return instrumentCopyFrom(t.metrics, tableName, columnNames, func() (int64, error) {
+		return t.Tx.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	})
+}
+
+func (t *instrumentedTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	start := time.Now()
+	row := t.Tx.QueryRow(ctx, sql, args...)
+
+	return &instrumentedRow{
+		Row:     row,
+		metrics: t.metrics,
+		sql:     sql,
+		start:   start,
+	}
+}
+
+// Record a COPY FROM as the equivalent COPY ... FROM STDIN statement, with the rows copied as affected
+func instrumentCopyFrom(metrics *Metrics, tableName pgx.Identifier, columnNames []string, copyFrom func() (int64, error)) (int64, error) {
+	start := time.Now()
+	copied, err := copyFrom()
+
+	sql := "COPY " + tableName.Sanitize() + " (" + ToColumnList(columnNames...) + ") FROM STDIN"
+	metrics.Observe(sql, time.Since(start), 0, copied, err)
+
+	return copied, err
+}
+
+func instrumentQuery(metrics *Metrics, sql string, query func() (pgx.Rows, error)) (pgx.Rows, error) {
+	start := time.Now()
+	rows, err := query()
+	if err != nil {
+		metrics.Observe(sql, time.Since(start), 0, 0, err)
+		return rows, err
+	}
+
+	return &instrumentedRows{
+		Rows:    rows,
+		metrics: metrics,
+		sql:     sql,
+		start:   start,
+	}, nil
+}
+
+// Rows which record their metrics once they've been fully read or closed
+// Latency covers the time from sending the query until the result set is done
+type instrumentedRows struct {
+	pgx.Rows
+	metrics  *Metrics
+	sql      string
+	start    time.Time
+	scanned  int64
+	observed bool
+}
+
+func (r *instrumentedRows) Next() bool {
+	if r.Rows.Next() {
+		r.scanned += 1
+		return true
+	}
+
+	r.observe()
+	return false
+}
+
+func (r *instrumentedRows) Close() {
+	r.Rows.Close()
+	r.observe()
+}
+
+func (r *instrumentedRows) observe() {
+	if r.observed {
+		return
+	}
+
+	r.observed = true
+	r.metrics.Observe(r.sql, time.Since(r.start), r.scanned, r.Rows.CommandTag().RowsAffected(), r.Rows.Err())
+}
+
+type instrumentedRow struct {
+	pgx.Row
+	metrics *Metrics
+	sql     string
+	start   time.Time
+}
+
+func (r *instrumentedRow) Scan(dest ...interface{}) error {
+	err := r.Row.Scan(dest...)
+
+	if err == pgx.ErrNoRows {
+		r.metrics.Observe(r.sql, time.Since(r.start), 0, 0, nil)
+	} else if err != nil {
+		r.metrics.Observe(r.sql, time.Since(r.start), 0, 0, err)
+	} else {
+		r.metrics.Observe(r.sql, time.Since(r.start), 1, 0, nil)
+	}
+
+	return err
+}
@@ -0,0 +1,139 @@
+package pgxload
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+// Delays QueryRow, standing in for the round trip pgx makes before QueryRow returns
+type slowQueryRowConn struct {
+	PGXConn
+	delay time.Duration
+}
+
+func (c *slowQueryRowConn) QueryRow(ctx context.Context, sql string, optionsAndArgs ...interface{}) pgx.Row {
+	time.Sleep(c.delay)
+	return c.PGXConn.QueryRow(ctx, sql, optionsAndArgs...)
+}
+
+func metricsFor(m *Metrics, sql string) StatementMetrics {
+	fingerprint := FingerprintSQL(sql)
+	for _, s := range m.Snapshot() {
+		if s.Fingerprint == fingerprint {
+			return s
+		}
+	}
+
+	return StatementMetrics{}
+}
+
+func TestInstrumentedConn(t *testing.T) {
+
+	ctx := context.Background()
+
+	fake := pgxloadtest.NewConn()
+	metrics := NewMetrics()
+	conn := NewInstrumentedConn(&slowQueryRowConn{PGXConn: fake, delay: 20 * time.Millisecond}, metrics)
+
+	fake.ExpectExec("delete from users").WillReturnResult("DELETE 3")
+	fake.ExpectQuery("select id from users").WillReturnRows(pgxloadtest.NewRows("id").AddRow(int64(1)).AddRow(int64(2)))
+	fake.ExpectQuery("select id from users where id = $1").WillReturnRows(pgxloadtest.NewRows("id").AddRow(int64(1)))
+	fake.ExpectQuery("select name from users where id = $1").WillReturnRows(pgxloadtest.NewRows("name"))
+
+	_, err := conn.Exec(ctx, "delete from users")
+	assert.NoError(t, err)
+
+	rows, err := conn.Query(ctx, "select id from users")
+	if assert.NoError(t, err) {
+		for rows.Next() {
+		}
+		rows.Close()
+	}
+
+	var id int64
+	assert.NoError(t, conn.QueryRow(ctx, "select id from users where id = $1", 1).Scan(&id))
+	assert.Equal(t, pgx.ErrNoRows, conn.QueryRow(ctx, "select name from users where id = $1", 1).Scan(&id))
+
+	assert.Equal(t, int64(3), metricsFor(metrics, "delete from users").RowsAffected)
+
+	query := metricsFor(metrics, "select id from users")
+	assert.Equal(t, int64(1), query.Count)
+	assert.Equal(t, int64(2), query.RowsScanned)
+
+	row := metricsFor(metrics, "select id from users where id = $1")
+	assert.Equal(t, int64(1), row.RowsScanned)
+	assert.True(t, row.MaxLatency >= 20*time.Millisecond, "QueryRow latency %s excludes the query", row.MaxLatency)
+
+	noRow := metricsFor(metrics, "select name from users where id = $1")
+	assert.Equal(t, int64(1), noRow.Count)
+	assert.Equal(t, int64(0), noRow.Errors)
+
+	assert.NoError(t, fake.ExpectationsWereMet())
+}
+
+func TestInstrumentedConn_Tx(t *testing.T) {
+
+	ctx := context.Background()
+
+	fake := pgxloadtest.NewConn()
+	metrics := NewMetrics()
+	conn := NewInstrumentedConn(fake, metrics)
+
+	fake.ExpectBegin()
+	fake.ExpectExec("update users set name = $1").WillReturnResult("UPDATE 2")
+	fake.ExpectQuery("select id from users").WillReturnRows(pgxloadtest.NewRows("id").AddRow(int64(1)))
+	fake.ExpectQuery("select id from users where id = $1").WillReturnRows(pgxloadtest.NewRows("id").AddRow(int64(1)))
+	fake.ExpectCommit()
+
+	tx, err := conn.Begin(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = tx.Exec(ctx, "update users set name = $1", "a")
+	assert.NoError(t, err)
+
+	rows, err := tx.Query(ctx, "select id from users")
+	if assert.NoError(t, err) {
+		rows.Close()
+	}
+
+	var id int64
+	assert.NoError(t, tx.QueryRow(ctx, "select id from users where id = $1", 1).Scan(&id))
+	assert.NoError(t, tx.Commit(ctx))
+
+	assert.Equal(t, int64(2), metricsFor(metrics, "update users set name = $1").RowsAffected)
+	assert.Equal(t, int64(1), metricsFor(metrics, "select id from users").Count)
+	assert.Equal(t, int64(1), metricsFor(metrics, "select id from users where id = $1").RowsScanned)
+	assert.NoError(t, fake.ExpectationsWereMet())
+}
+
+func TestInstrumentedConn_CopyFrom(t *testing.T) {
+
+	ctx := context.Background()
+
+	fake := pgxloadtest.NewConn()
+	metrics := NewMetrics()
+	loader, _ := NewPgxLoader(NewInstrumentedConn(fake, metrics))
+
+	fake.ExpectCopyFrom("public.users", "name", "nickname")
+	fake.ExpectCopyFrom("public.users", "name", "nickname").WillReturnError(assert.AnError)
+
+	_, err := CopyStructs(ctx, loader, "public.users", []copyRow{{Name: "a"}, {Name: "b"}})
+	assert.NoError(t, err)
+
+	_, err = CopyStructs(ctx, loader, "public.users", []copyRow{{Name: "c"}})
+	assert.Equal(t, assert.AnError, err)
+
+	copied := metricsFor(metrics, `COPY "public"."users" ("name", "nickname") FROM STDIN`)
+	assert.Equal(t, int64(2), copied.Count)
+	assert.Equal(t, int64(1), copied.Errors)
+	assert.Equal(t, int64(2), copied.RowsAffected)
+
+	assert.NoError(t, fake.ExpectationsWereMet())
+}
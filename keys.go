@@ -0,0 +1,85 @@
+package pgxload
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Resolve a key to the scalar it represents, so equal keys compare and format the same
+// Pointers are followed, driver.Valuers are evaluated and times are normalised to UTC.
+// Returns nil for nil pointers and NULL values, and an error for keys which aren't scalars
+func keyValue(key interface{}) (interface{}, error) {
+
+	for key != nil {
+		val := reflect.ValueOf(key)
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return nil, nil
+			}
+
+			if valuer, ok := key.(driver.Valuer); ok {
+				v, err := valuer.Value()
+				if err != nil {
+					return nil, err
+				}
+
+				key = v
+				continue
+			}
+
+			key = val.Elem().Interface()
+			continue
+		}
+
+		if valuer, ok := key.(driver.Valuer); ok {
+			v, err := valuer.Value()
+			if err != nil {
+				return nil, err
+			}
+
+			if reflect.TypeOf(v) == reflect.TypeOf(key) {
+				return nil, fmt.Errorf("unsupported key type %T", key)
+			}
+
+			key = v
+			continue
+		}
+
+		switch k := key.(type) {
+		case time.Time:
+			return k.UTC(), nil
+		case []byte:
+			return string(k), nil
+		}
+
+		switch val.Kind() {
+		case reflect.Bool, reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return key, nil
+		}
+
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return nil, nil
+}
+
+// Comparable key of values, each resolved with keyValue
+func matchKey(values []interface{}) (string, error) {
+
+	keys := make([]interface{}, len(values))
+	for idx, val := range values {
+		key, err := keyValue(val)
+		if err != nil {
+			return "", err
+		}
+
+		keys[idx] = key
+	}
+
+	return fmt.Sprintf("%#v", keys), nil
+}
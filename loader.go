@@ -1,8 +1,10 @@
 package pgxload
 
 import (
+	"context"
 	"errors"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jmoiron/sqlx/reflectx"
 )
@@ -25,10 +27,23 @@ type PgxLoader interface {
 	CommonLoader
 }
 
+// A loader able to run queries, satisfied by both PgxLoader and PgxTxLoader
+type QueryLoader interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, optionsAndArgs ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, optionsAndArgs ...interface{}) pgx.Row
+
+	// The common loader funcs
+	CommonLoader
+}
+
 // Configuration options to pass to reflectx.NewMapperFunc
 type Config struct {
 	StructTag string
 	Mapper    func(string) string
+
+	// Optional collector to record per-statement metrics into
+	Metrics *Metrics
 }
 
 func (c *Config) generateMapper() *reflectx.Mapper {
@@ -51,6 +66,10 @@ func NewPgxLoader(conn PGXConn, config ...*Config) (PgxLoader, error) {
 	}
 
 	if len(config) == 1 && config[0] != nil {
+		if config[0].Metrics != nil {
+			conn = NewInstrumentedConn(conn, config[0].Metrics)
+		}
+
 		return &pgxLoader{
 			mapper:  config[0].generateMapper(),
 			PGXConn: conn,
@@ -74,6 +93,15 @@ func (p *pgxLoader) Mapper() *reflectx.Mapper {
 	return p.mapper
 }
 
+func (p *pgxLoader) unwrapConn(ctx context.Context) interface{} {
+	return p.PGXConn
+}
+
+// COPY FROM using the underlying connection, see CopyLoader
+func (p *pgxLoader) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return copyFromConn(ctx, p.PGXConn, tableName, columnNames, rowSrc)
+}
+
 // Create a new Scanner for the specified rows and the underlying reflectx mapper
 func (p *pgxLoader) Scanner(rows pgx.Rows) Scanner {
 
@@ -0,0 +1,249 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// How a replica loader picks which replica to read from
+type ReplicaStrategy int
+
+const (
+	// Cycle through replicas in order
+	RoundRobin ReplicaStrategy = iota
+
+	// Pick the replica with the lowest moving average query latency, occasionally probing the others
+	LeastLatency
+)
+
+// Configuration options to pass to NewReplicaPgxLoader
+type ReplicaConfig struct {
+	// Mapper and metrics configuration, if nil DefaultConfig is used
+	Loader *Config
+
+	Strategy ReplicaStrategy
+}
+
+type primaryReadsContextKey struct{}
+
+// Force Query and QueryRow calls made with the returned context to read from the primary
+// Use for read-your-writes, when replication lag can't be tolerated
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsContextKey{}, true)
+}
+
+func primaryReads(ctx context.Context) bool {
+	force, _ := ctx.Value(primaryReadsContextKey{}).(bool)
+	return force
+}
+
+// Create a new PgxLoader which sends Exec and transactions to primary, and spreads Query and QueryRow across replicas
+// If there are no replicas all calls go to primary
+func NewReplicaPgxLoader(primary PGXConn, replicas []PGXConn, config ...*ReplicaConfig) (PgxLoader, error) {
+
+	if len(config) > 1 {
+		return nil, errors.New("specify only 1 config option")
+	}
+
+	if primary == nil {
+		return nil, errors.New("missing primary connection")
+	}
+
+	cfg := &ReplicaConfig{}
+	if len(config) == 1 && config[0] != nil {
+		cfg = config[0]
+	}
+
+	loaderConfig := cfg.Loader
+	if loaderConfig == nil {
+		loaderConfig = DefaultConfig
+	}
+
+	if loaderConfig.Metrics != nil {
+		primary = NewInstrumentedConn(primary, loaderConfig.Metrics)
+	}
+
+	conns := make([]PGXConn, len(replicas))
+	for idx, replica := range replicas {
+		if replica == nil {
+			return nil, errors.New("replica connections must be non-nil")
+		}
+
+		if loaderConfig.Metrics != nil {
+			replica = NewInstrumentedConn(replica, loaderConfig.Metrics)
+		}
+
+		conns[idx] = replica
+	}
+
+	return &replicaPgxLoader{
+		PGXConn:  primary,
+		mapper:   loaderConfig.generateMapper(),
+		replicas: conns,
+		strategy: cfg.Strategy,
+		latency:  make([]time.Duration, len(conns)),
+		failed:   make([]bool, len(conns)),
+	}, nil
+}
+
+// Smoothing factor for the latency moving average, out of 10
+const replicaLatencyWeight = 2
+
+// Latency recorded for a failed query, so a replica failing fast isn't mistaken for the quickest
+const replicaErrorPenalty = time.Second
+
+// Every nth LeastLatency read goes to the next replica in turn instead, so replicas which have
+// recovered from errors or slowness are measured again rather than starved
+const replicaProbeInterval = 10
+
+type replicaPgxLoader struct {
+	// Round robin counter, first so it's 64 bit aligned for atomic access
+	next uint64
+
+	// The primary connection, which handles Exec, transactions and forced primary reads
+	PGXConn
+	mapper *reflectx.Mapper
+
+	replicas []PGXConn
+	strategy ReplicaStrategy
+
+	mu      sync.Mutex
+	latency []time.Duration
+
+	// Whether the replica's last read failed, its latency is the error penalty until one succeeds
+	failed []bool
+}
+
+// The reflectx mapper this loader uses
+func (r *replicaPgxLoader) Mapper() *reflectx.Mapper {
+	return r.mapper
+}
+
+// COPY TO is a read, so runs against a replica picked like Query, or the primary with WithPrimaryReads
+// Its latency isn't recorded for LeastLatency, as it depends on the size of the extract
+func (r *replicaPgxLoader) unwrapConn(ctx context.Context) interface{} {
+
+	if primaryReads(ctx) || len(r.replicas) == 0 {
+		return r.PGXConn
+	}
+
+	return r.replicas[r.pickReplica()]
+}
+
+// COPY FROM runs against the primary
+func (r *replicaPgxLoader) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return copyFromConn(ctx, r.PGXConn, tableName, columnNames, rowSrc)
+}
+
+// Create a new Scanner for the specified rows and the underlying reflectx mapper
+func (r *replicaPgxLoader) Scanner(rows pgx.Rows) Scanner {
+
+	return NewScanner(rows, r.Mapper())
+}
+
+func (r *replicaPgxLoader) Query(ctx context.Context, sql string, optionsAndArgs ...interface{}) (pgx.Rows, error) {
+
+	if primaryReads(ctx) || len(r.replicas) == 0 {
+		return r.PGXConn.Query(ctx, sql, optionsAndArgs...)
+	}
+
+	idx := r.pickReplica()
+
+	start := time.Now()
+	rows, err := r.replicas[idx].Query(ctx, sql, optionsAndArgs...)
+	r.recordLatency(idx, time.Since(start), err)
+
+	return rows, err
+}
+
+func (r *replicaPgxLoader) QueryRow(ctx context.Context, sql string, optionsAndArgs ...interface{}) pgx.Row {
+
+	if primaryReads(ctx) || len(r.replicas) == 0 {
+		return r.PGXConn.QueryRow(ctx, sql, optionsAndArgs...)
+	}
+
+	idx := r.pickReplica()
+
+	start := time.Now()
+	row := r.replicas[idx].QueryRow(ctx, sql, optionsAndArgs...)
+
+	if r.strategy != LeastLatency {
+		return row
+	}
+
+	return &replicaRow{Row: row, loader: r, idx: idx, start: start}
+}
+
+// Records the replica's latency once the row is scanned, as QueryRow errors only surface from Scan
+type replicaRow struct {
+	pgx.Row
+	loader *replicaPgxLoader
+	idx    int
+	start  time.Time
+}
+
+func (r *replicaRow) Scan(dest ...interface{}) error {
+	err := r.Row.Scan(dest...)
+	if err == pgx.ErrNoRows {
+		r.loader.recordLatency(r.idx, time.Since(r.start), nil)
+	} else {
+		r.loader.recordLatency(r.idx, time.Since(r.start), err)
+	}
+
+	return err
+}
+
+func (r *replicaPgxLoader) pickReplica() int {
+
+	if r.strategy == LeastLatency {
+		n := atomic.AddUint64(&r.next, 1) - 1
+		if n%replicaProbeInterval == replicaProbeInterval-1 {
+			return int((n / replicaProbeInterval) % uint64(len(r.replicas)))
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		best := 0
+		for idx, latency := range r.latency {
+			if latency < r.latency[best] {
+				best = idx
+			}
+		}
+
+		return best
+	}
+
+	return int((atomic.AddUint64(&r.next, 1) - 1) % uint64(len(r.replicas)))
+}
+
+func (r *replicaPgxLoader) recordLatency(idx int, latency time.Duration, err error) {
+
+	if r.strategy != LeastLatency {
+		return
+	}
+
+	if err != nil && latency < replicaErrorPenalty {
+		latency = replicaErrorPenalty
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The penalty isn't a real measurement, so a recovered replica starts over from its actual latency
+	wasFailed := r.failed[idx]
+	r.failed[idx] = err != nil
+
+	if r.latency[idx] == 0 || wasFailed && err == nil {
+		r.latency[idx] = latency
+		return
+	}
+
+	r.latency[idx] = (r.latency[idx]*(10-replicaLatencyWeight) + latency*replicaLatencyWeight) / 10
+}
@@ -0,0 +1,183 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type routedConn struct {
+	PGXConn
+	calls *[]string
+	name  string
+	err   error
+}
+
+func (r routedConn) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	*r.calls = append(*r.calls, r.name)
+	return nil, nil
+}
+
+func (r routedConn) Query(ctx context.Context, sql string, optionsAndArgs ...interface{}) (pgx.Rows, error) {
+	*r.calls = append(*r.calls, r.name)
+	return nil, r.err
+}
+
+func (r routedConn) QueryRow(ctx context.Context, sql string, optionsAndArgs ...interface{}) pgx.Row {
+	*r.calls = append(*r.calls, r.name)
+	return routedRow{err: r.err}
+}
+
+// A replica whose queries fail until failures reaches 0, then recovers
+type flakyConn struct {
+	routedConn
+	failures *int
+}
+
+func (f flakyConn) Query(ctx context.Context, sql string, optionsAndArgs ...interface{}) (pgx.Rows, error) {
+	*f.calls = append(*f.calls, f.name)
+	if *f.failures > 0 {
+		*f.failures -= 1
+		return nil, errors.New("connection refused")
+	}
+
+	return nil, nil
+}
+
+type routedRow struct {
+	err error
+}
+
+func (r routedRow) Scan(dest ...interface{}) error {
+	return r.err
+}
+
+func TestReplicaPgxLoader_Routing(t *testing.T) {
+
+	ctx := context.Background()
+
+	var calls []string
+	loader, err := NewReplicaPgxLoader(
+		routedConn{calls: &calls, name: "primary"},
+		[]PGXConn{routedConn{calls: &calls, name: "r1"}, routedConn{calls: &calls, name: "r2"}},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, _ = loader.Query(ctx, "select 1")
+	_, _ = loader.Query(ctx, "select 1")
+	_, _ = loader.Query(ctx, "select 1")
+	_, _ = loader.Exec(ctx, "delete from t")
+	_, _ = loader.Query(WithPrimaryReads(ctx), "select 1")
+
+	assert.Equal(t, []string{"r1", "r2", "r1", "primary", "primary"}, calls)
+}
+
+func TestReplicaPgxLoader_QueryRowRouting(t *testing.T) {
+
+	ctx := context.Background()
+
+	var calls []string
+	loader, err := NewReplicaPgxLoader(
+		routedConn{calls: &calls, name: "primary"},
+		[]PGXConn{routedConn{calls: &calls, name: "r1"}, routedConn{calls: &calls, name: "r2"}},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_ = loader.QueryRow(ctx, "select 1").Scan()
+	_ = loader.QueryRow(ctx, "select 1").Scan()
+	_ = loader.QueryRow(WithPrimaryReads(ctx), "select 1").Scan()
+
+	assert.Equal(t, []string{"r1", "r2", "primary"}, calls)
+}
+
+func TestReplicaPgxLoader_CopyToRouting(t *testing.T) {
+
+	ctx := context.Background()
+
+	var calls []string
+	loader, err := NewReplicaPgxLoader(
+		routedConn{calls: &calls, name: "primary"},
+		[]PGXConn{routedConn{calls: &calls, name: "r1"}, routedConn{calls: &calls, name: "r2"}},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	replicaLoader := loader.(*replicaPgxLoader)
+
+	var conns []string
+	for _, copyCtx := range []context.Context{ctx, ctx, WithPrimaryReads(ctx)} {
+		conns = append(conns, replicaLoader.unwrapConn(copyCtx).(routedConn).name)
+	}
+
+	assert.Equal(t, []string{"r1", "r2", "primary"}, conns)
+}
+
+func TestReplicaPgxLoader_LeastLatency(t *testing.T) {
+
+	ctx := context.Background()
+
+	var calls []string
+	loader, err := NewReplicaPgxLoader(
+		routedConn{calls: &calls, name: "primary"},
+		[]PGXConn{
+			routedConn{calls: &calls, name: "down", err: errors.New("connection refused")},
+			routedConn{calls: &calls, name: "up"},
+		},
+		&ReplicaConfig{Strategy: LeastLatency},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The failing replica is tried first, then avoided despite failing faster than any query could succeed
+	for i := 0; i < 3; i++ {
+		_, _ = loader.Query(ctx, "select 1")
+	}
+	assert.Equal(t, []string{"down", "up", "up"}, calls)
+
+	calls = nil
+	_ = loader.QueryRow(ctx, "select 1").Scan()
+	_ = loader.QueryRow(ctx, "select 1").Scan()
+	assert.Equal(t, []string{"up", "up"}, calls)
+}
+
+func TestReplicaPgxLoader_LeastLatencyRecovery(t *testing.T) {
+
+	ctx := context.Background()
+
+	var calls []string
+	failures := 1
+	loader, err := NewReplicaPgxLoader(
+		routedConn{calls: &calls, name: "primary"},
+		[]PGXConn{
+			flakyConn{routedConn: routedConn{calls: &calls, name: "flaky"}, failures: &failures},
+			routedConn{calls: &calls, name: "up"},
+		},
+		&ReplicaConfig{Strategy: LeastLatency},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < replicaProbeInterval; i++ {
+		_, _ = loader.Query(ctx, "select 1")
+	}
+
+	// The flaky replica fails, is avoided, then probed once it has recovered
+	assert.Equal(t, "flaky", calls[0])
+	assert.NotContains(t, calls[1:replicaProbeInterval-1], "flaky")
+	assert.Equal(t, "flaky", calls[replicaProbeInterval-1])
+
+	replicaLoader := loader.(*replicaPgxLoader)
+	assert.False(t, replicaLoader.failed[0])
+	assert.True(t, replicaLoader.latency[0] < replicaErrorPenalty)
+}
@@ -0,0 +1,219 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+)
+
+// Maps a shard key to the index of the shard it lives on, out of shardCount shards
+type ShardFunc func(key interface{}, shardCount int) (int, error)
+
+// Default ShardFunc, hashing the string representation of the key with FNV-1a
+// Pointers and driver.Valuers are hashed by the value they hold, keys which aren't scalars are an error
+func HashShardFunc(key interface{}, shardCount int) (int, error) {
+
+	key, err := keyValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if key == nil {
+		return 0, errors.New("missing shard key")
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fmt.Sprint(key)))
+
+	return int(h.Sum32() % uint32(shardCount)), nil
+}
+
+// Create a new ShardedLoader routing across shards using shardFn
+// If shardFn is nil HashShardFunc is used. All shards should share the same mapper configuration
+func NewShardedLoader(shardFn ShardFunc, shards ...PgxLoader) (*ShardedLoader, error) {
+
+	if len(shards) == 0 {
+		return nil, errors.New("specify at least 1 shard")
+	}
+
+	if shardFn == nil {
+		shardFn = HashShardFunc
+	}
+
+	return &ShardedLoader{
+		shards:  shards,
+		shardFn: shardFn,
+	}, nil
+}
+
+// Routes statements to one of several loaders, by a shard key passed explicitly,
+// or extracted from the field of a struct tagged with `pgxload:"shardKey"`
+type ShardedLoader struct {
+	shards  []PgxLoader
+	shardFn ShardFunc
+}
+
+// All shards, in the order they were specified
+func (s *ShardedLoader) Shards() []PgxLoader {
+	return s.shards
+}
+
+// The shard the specified key lives on
+func (s *ShardedLoader) Shard(key interface{}) (PgxLoader, error) {
+
+	idx, err := s.shardIndex(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.shards[idx], nil
+}
+
+// The shard the specified struct lives on, based on its shardKey tagged field
+func (s *ShardedLoader) ShardOf(data interface{}) (PgxLoader, error) {
+
+	extractor, err := NewStructColumnValueExtractor(s.shards[0].Mapper(), data)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := extractor.ExtractShardKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Shard(key)
+}
+
+func (s *ShardedLoader) shardIndex(key interface{}) (int, error) {
+
+	idx, err := s.shardFn(key, len(s.shards))
+	if err != nil {
+		return 0, err
+	}
+
+	if idx < 0 || idx >= len(s.shards) {
+		return 0, fmt.Errorf("shard func returned shard %d, but there are %d shards", idx, len(s.shards))
+	}
+
+	return idx, nil
+}
+
+// Execute an insert, splitting its rows across shards by their shardKey tagged field
+// Each shard receives one statement, returns the total rows affected across all shards.
+// This isn't atomic: each shard commits on its own, so on error the shards before the failing one keep their rows,
+// which are included in the rows affected returned alongside the error
+func (s *ShardedLoader) ExecStructInsert(ctx context.Context, ins StructInsert) (int64, error) {
+
+	if len(ins.data) == 0 {
+		return 0, errors.New("missing input to insert")
+	}
+
+	extractor, err := NewStructColumnValueExtractor(s.shards[0].Mapper(), ins.data[0])
+	if err != nil {
+		return 0, err
+	}
+
+	byShard := make([][]interface{}, len(s.shards))
+	for _, dat := range ins.data {
+		key, err := extractor.ExtractShardKey(dat)
+		if err != nil {
+			return 0, err
+		}
+
+		idx, err := s.shardIndex(key)
+		if err != nil {
+			return 0, err
+		}
+
+		byShard[idx] = append(byShard[idx], dat)
+	}
+
+	var affected int64
+	for idx, data := range byShard {
+		if len(data) == 0 {
+			continue
+		}
+
+		shard := s.shards[idx]
+
+		stmt, params, err := ins.withData(data).GenerateInsert(shard.Mapper())
+		if err != nil {
+			return affected, err
+		}
+
+		tag, err := shard.Exec(ctx, stmt, params...)
+		if err != nil {
+			return affected, err
+		}
+
+		affected += tag.RowsAffected()
+	}
+
+	return affected, nil
+}
+
+// Execute an exact update on the shard its data lives on, based on its shardKey tagged field
+// An update WithSnapshot where nothing has changed is skipped, returning 0 rows affected
+func (s *ShardedLoader) ExecStructUpdate(ctx context.Context, upd StructUpdate, columnsToMatch ...string) (int64, error) {
+
+	shard, err := s.ShardOf(upd.data)
+	if err != nil {
+		return 0, err
+	}
+
+	return ExecStructUpdate(ctx, shard, upd, columnsToMatch...)
+}
+
+// Run a query against every shard concurrently, scanning all results into dest
+// dest must be a pointer to a slice, rows are appended in shard order
+func (s *ShardedLoader) FanOutQuery(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+
+	val, err := prepareInput(dest)
+	if err != nil {
+		return err
+	}
+
+	if val.Kind() != reflect.Slice {
+		return errors.New("fan out query destination must be a pointer to a slice")
+	}
+
+	results := make([]reflect.Value, len(s.shards))
+	errs := make([]error, len(s.shards))
+
+	var wg sync.WaitGroup
+	for idx, shard := range s.shards {
+		wg.Add(1)
+
+		go func(idx int, shard PgxLoader) {
+			defer wg.Done()
+
+			results[idx] = reflect.New(val.Type())
+
+			rows, err := shard.Query(ctx, sql, args...)
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+
+			errs[idx] = shard.Scanner(rows).Scan(results[idx].Interface())
+		}(idx, shard)
+	}
+
+	wg.Wait()
+
+	for idx, err := range errs {
+		if err != nil {
+			return fmt.Errorf("shard %d: %w", idx, err)
+		}
+	}
+
+	for _, result := range results {
+		val.Set(reflect.AppendSlice(val, result.Elem()))
+	}
+
+	return nil
+}
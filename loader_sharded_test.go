@@ -0,0 +1,164 @@
+package pgxload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+type shardedRow struct {
+	TenantID int `pgxload:"shardKey"`
+	Name     string
+}
+
+func TestShardedLoader_ExecStructInsert(t *testing.T) {
+
+	var calls []string
+	shard0, _ := NewPgxLoader(routedConn{calls: &calls, name: "shard0"})
+	shard1, _ := NewPgxLoader(routedConn{calls: &calls, name: "shard1"})
+
+	loader, err := NewShardedLoader(func(key interface{}, shardCount int) (int, error) {
+		return key.(int) % shardCount, nil
+	}, shard0, shard1)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = loader.ExecStructInsert(context.Background(), NewStructInsert("rows",
+		shardedRow{TenantID: 1, Name: "a"},
+		shardedRow{TenantID: 3, Name: "b"},
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shard1"}, calls)
+
+	shard, err := loader.ShardOf(&shardedRow{TenantID: 4})
+	if assert.NoError(t, err) {
+		assert.Equal(t, shard0, shard)
+	}
+
+	_, err = loader.ShardOf(&Config{})
+	assert.Error(t, err)
+}
+
+func TestHashShardFunc_PointerKey(t *testing.T) {
+
+	a, b := "tenant", "tenant"
+
+	shardA, err := HashShardFunc(&a, 16)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	shardB, err := HashShardFunc(&b, 16)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	shardValue, err := HashShardFunc(a, 16)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, shardValue, shardA)
+	assert.Equal(t, shardValue, shardB)
+
+	_, err = HashShardFunc((*string)(nil), 16)
+	assert.Error(t, err)
+
+	_, err = HashShardFunc(struct{ ID int }{1}, 16)
+	assert.Error(t, err)
+}
+
+func TestShardedLoader_ExecStructUpdate(t *testing.T) {
+
+	ctx := context.Background()
+
+	var calls []string
+	shard0, _ := NewPgxLoader(routedConn{calls: &calls, name: "shard0"})
+	shard1, _ := NewPgxLoader(routedConn{calls: &calls, name: "shard1"})
+
+	loader, err := NewShardedLoader(func(key interface{}, shardCount int) (int, error) {
+		return key.(int) % shardCount, nil
+	}, shard0, shard1)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	row := &shardedRow{TenantID: 3, Name: "a"}
+
+	_, err = loader.ExecStructUpdate(ctx, NewStructUpdate("rows", row), "tenant_id")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shard1"}, calls)
+
+	snapshot, err := Track(row)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	calls = nil
+	affected, err := loader.ExecStructUpdate(ctx, NewStructUpdate("rows", row).WithSnapshot(snapshot), "tenant_id")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+	assert.Empty(t, calls)
+}
+
+func TestShardedLoader_ExecStructInsertPartialFailure(t *testing.T) {
+
+	conn0, conn1 := pgxloadtest.NewConn(), pgxloadtest.NewConn()
+	shard0, _ := NewPgxLoader(conn0)
+	shard1, _ := NewPgxLoader(conn1)
+
+	loader, err := NewShardedLoader(func(key interface{}, shardCount int) (int, error) {
+		return key.(int) % shardCount, nil
+	}, shard0, shard1)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	conn0.ExpectExecRegex(`^INSERT INTO rows`).WillReturnResult("INSERT 0 1")
+	conn1.ExpectExecRegex(`^INSERT INTO rows`).WillReturnError(assert.AnError)
+
+	affected, err := loader.ExecStructInsert(context.Background(), NewStructInsert("rows",
+		shardedRow{TenantID: 2, Name: "a"},
+		shardedRow{TenantID: 3, Name: "b"},
+	))
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, int64(1), affected)
+	assert.NoError(t, conn0.ExpectationsWereMet())
+	assert.NoError(t, conn1.ExpectationsWereMet())
+}
+
+func TestShardedLoader_FanOutQuery(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn0, conn1 := pgxloadtest.NewConn(), pgxloadtest.NewConn()
+	shard0, _ := NewPgxLoader(conn0)
+	shard1, _ := NewPgxLoader(conn1)
+
+	loader, err := NewShardedLoader(nil, shard0, shard1)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	conn0.ExpectQuery("select tenant_id, name from rows").
+		WillReturnRows(pgxloadtest.NewRows("tenant_id", "name").AddRow(2, "a").AddRow(4, "c"))
+	conn1.ExpectQuery("select tenant_id, name from rows").
+		WillReturnRows(pgxloadtest.NewRows("tenant_id", "name").AddRow(3, "b"))
+
+	var rows []shardedRow
+	if assert.NoError(t, loader.FanOutQuery(ctx, &rows, "select tenant_id, name from rows")) {
+		assert.Equal(t, []shardedRow{{TenantID: 2, Name: "a"}, {TenantID: 4, Name: "c"}, {TenantID: 3, Name: "b"}}, rows)
+	}
+
+	conn0.ExpectQuery("select tenant_id, name from rows").WillReturnRows(pgxloadtest.NewRows("tenant_id", "name"))
+	conn1.ExpectQuery("select tenant_id, name from rows").WillReturnError(assert.AnError)
+
+	err = loader.FanOutQuery(ctx, &rows, "select tenant_id, name from rows")
+	assert.EqualError(t, err, "shard 1: "+assert.AnError.Error())
+
+	assert.NoError(t, conn0.ExpectationsWereMet())
+	assert.NoError(t, conn1.ExpectationsWereMet())
+}
@@ -1,6 +1,9 @@
 package pgxload
 
 import (
+	"context"
+	"sync"
+
 	"github.com/jackc/pgx/v4"
 	"github.com/jmoiron/sqlx/reflectx"
 )
@@ -9,6 +12,12 @@ import (
 type PgxTxLoader interface {
 	pgx.Tx
 
+	// Register a func to run once the transaction has committed
+	OnCommit(fn func(ctx context.Context))
+
+	// Register a func to run once the transaction has rolled back
+	OnRollback(fn func(ctx context.Context))
+
 	// The common loader funcs
 	CommonLoader
 }
@@ -21,9 +30,25 @@ func NewPgxTxLoader(existing PgxLoader, tx pgx.Tx) PgxTxLoader {
 	}
 }
 
+// Create a new PgxTxLoader for a pseudo nested transaction (savepoint) started within parent
+// Callbacks registered on a released savepoint are handed to parent, as the outcome is only known once parent finishes
+func newNestedPgxTxLoader(parent PgxTxLoader, tx pgx.Tx) PgxTxLoader {
+
+	return &pgxTxLoader{
+		loader: parent,
+		parent: parent,
+		Tx:     tx,
+	}
+}
+
 type pgxTxLoader struct {
 	pgx.Tx
-	loader PgxLoader
+	loader CommonLoader
+	parent PgxTxLoader
+
+	mu         sync.Mutex
+	onCommit   []func(ctx context.Context)
+	onRollback []func(ctx context.Context)
 }
 
 // The reflectx mapper this loader uses
@@ -36,3 +61,86 @@ func (p *pgxTxLoader) Scanner(rows pgx.Rows) Scanner {
 
 	return NewScanner(rows, p.Mapper())
 }
+
+// Register a func to run once the transaction has committed
+func (p *pgxTxLoader) OnCommit(fn func(ctx context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onCommit = append(p.onCommit, fn)
+}
+
+// Register a func to run once the transaction has rolled back
+func (p *pgxTxLoader) OnRollback(fn func(ctx context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onRollback = append(p.onRollback, fn)
+}
+
+// Commit the transaction, then run the commit callbacks
+// If the commit fails the transaction is considered rolled back, and the rollback callbacks run instead
+func (p *pgxTxLoader) Commit(ctx context.Context) error {
+
+	err := p.Tx.Commit(ctx)
+	if err == pgx.ErrTxClosed {
+		return err
+	}
+
+	onCommit, onRollback := p.takeCallbacks()
+
+	if err != nil {
+		runTxCallbacks(ctx, onRollback)
+		return err
+	}
+
+	if p.parent != nil {
+		for _, fn := range onCommit {
+			p.parent.OnCommit(fn)
+		}
+		for _, fn := range onRollback {
+			p.parent.OnRollback(fn)
+		}
+		return nil
+	}
+
+	runTxCallbacks(ctx, onCommit)
+	return nil
+}
+
+// Rollback the transaction, then run the rollback callbacks
+func (p *pgxTxLoader) Rollback(ctx context.Context) error {
+
+	err := p.Tx.Rollback(ctx)
+	if err == pgx.ErrTxClosed {
+		return err
+	}
+
+	_, onRollback := p.takeCallbacks()
+	runTxCallbacks(ctx, onRollback)
+
+	return err
+}
+
+func (p *pgxTxLoader) takeCallbacks() ([]func(ctx context.Context), []func(ctx context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	onCommit, onRollback := p.onCommit, p.onRollback
+	p.onCommit, p.onRollback = nil, nil
+
+	return onCommit, onRollback
+}
+
+// Run each callback, isolating panics so they can't affect the transaction result or other callbacks
+func runTxCallbacks(ctx context.Context, fns []func(ctx context.Context)) {
+	for _, fn := range fns {
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+
+			fn(ctx)
+		}()
+	}
+}
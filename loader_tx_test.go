@@ -0,0 +1,199 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+type mockTx struct {
+	pgx.Tx
+	commitErr error
+	closed    bool
+}
+
+func (m *mockTx) Commit(ctx context.Context) error {
+	if m.closed {
+		return pgx.ErrTxClosed
+	}
+	m.closed = true
+	return m.commitErr
+}
+
+func (m *mockTx) Rollback(ctx context.Context) error {
+	if m.closed {
+		return pgx.ErrTxClosed
+	}
+	m.closed = true
+	return nil
+}
+
+func TestPgxTxLoader_Callbacks(t *testing.T) {
+
+	ctx := context.Background()
+
+	var calls []string
+	record := func(name string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			calls = append(calls, name)
+		}
+	}
+
+	tx := NewPgxTxLoader(nil, &mockTx{})
+	tx.OnCommit(func(ctx context.Context) { panic("isolated") })
+	tx.OnCommit(record("commit"))
+	tx.OnRollback(record("rollback"))
+
+	assert.NoError(t, tx.Commit(ctx))
+	assert.Equal(t, pgx.ErrTxClosed, tx.Rollback(ctx))
+	assert.Equal(t, []string{"commit"}, calls)
+
+	calls = nil
+	tx = NewPgxTxLoader(nil, &mockTx{commitErr: pgx.ErrTxCommitRollback})
+	tx.OnCommit(record("commit"))
+	tx.OnRollback(record("rollback"))
+
+	assert.Equal(t, pgx.ErrTxCommitRollback, tx.Commit(ctx))
+	assert.Equal(t, []string{"rollback"}, calls)
+
+	calls = nil
+	tx = NewPgxTxLoader(nil, &mockTx{})
+	nested := newNestedPgxTxLoader(tx, &mockTx{})
+	nested.OnCommit(record("nested commit"))
+	nested.OnRollback(record("nested rollback"))
+	assert.NoError(t, nested.Commit(ctx))
+	assert.Empty(t, calls)
+
+	err := internalRunInTransaction(ctx, tx, func(ctx context.Context, tx PgxTxLoader) error {
+		return errors.New("failed")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"nested rollback"}, calls)
+}
+
+func TestLoaderFromContext(t *testing.T) {
+
+	ctx := context.Background()
+	fallback := &pgxLoader{mapper: DefaultConfig.generateMapper()}
+
+	assert.Equal(t, PgxLoader(fallback), LoaderFromContext(ctx, fallback))
+
+	tx := NewPgxTxLoader(fallback, &mockTx{})
+	resolved := LoaderFromContext(WithLoader(ctx, tx), fallback)
+
+	if assert.IsType(t, &joinedTxLoader{}, resolved) {
+		assert.Equal(t, tx, resolved.(*joinedTxLoader).PgxTxLoader)
+	}
+
+	_, err := resolved.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	assert.Equal(t, errJoinedTxOptions, err)
+}
+
+// Records the options each transaction is started with
+type txOptionsConn struct {
+	PGXConn
+	options []pgx.TxOptions
+}
+
+func (c *txOptionsConn) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	c.options = append(c.options, txOptions)
+	return c.PGXConn.BeginTx(ctx, txOptions)
+}
+
+func TestRunInTransactionWithOptions(t *testing.T) {
+
+	ctx := context.Background()
+
+	fake := pgxloadtest.NewConn()
+	conn := &txOptionsConn{PGXConn: fake}
+	loader, _ := NewPgxLoader(conn)
+
+	options := pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable}
+
+	fake.ExpectBegin()
+	fake.ExpectCommit()
+
+	err := RunInTransactionWithOptions(ctx, loader, options, func(ctx context.Context, tx PgxTxLoader) error {
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []pgx.TxOptions{options}, conn.options)
+	assert.NoError(t, fake.ExpectationsWereMet())
+}
+
+func TestRunInNestedTransaction(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	conn.ExpectBegin()
+	conn.ExpectBegin()
+	conn.ExpectExec("insert into a").WillReturnResult("INSERT 0 1")
+	conn.ExpectCommit()
+	conn.ExpectBegin()
+	conn.ExpectRollback()
+	conn.ExpectBegin()
+	conn.ExpectRollback()
+	conn.ExpectCommit()
+
+	err := RunInTransaction(ctx, loader, func(ctx context.Context, tx PgxTxLoader) error {
+
+		// Released on success
+		err := RunInNestedTransaction(ctx, tx, func(ctx context.Context, savepoint PgxTxLoader) error {
+			_, err := savepoint.Exec(ctx, "insert into a")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// Rolled back to on error
+		err = RunInNestedTransaction(ctx, tx, func(ctx context.Context, savepoint PgxTxLoader) error {
+			return errors.New("failed")
+		})
+		assert.EqualError(t, err, "failed")
+
+		// Rolled back to on panic
+		assert.Panics(t, func() {
+			_ = RunInNestedTransaction(ctx, tx, func(ctx context.Context, savepoint PgxTxLoader) error {
+				panic("boom")
+			})
+		})
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestRunInTransaction_OnCommitUsesOriginalContext(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	conn.ExpectBegin()
+	conn.ExpectExec("insert into orders").WillReturnResult("INSERT 0 1")
+	conn.ExpectCommit()
+	conn.ExpectExec("insert into outbox").WillReturnResult("INSERT 0 1")
+
+	var callbackErr error
+	err := RunInTransaction(ctx, loader, func(ctx context.Context, tx PgxTxLoader) error {
+		tx.OnCommit(func(ctx context.Context) {
+			_, callbackErr = LoaderFromContext(ctx, loader).Exec(ctx, "insert into outbox")
+		})
+
+		_, err := LoaderFromContext(ctx, loader).Exec(ctx, "insert into orders")
+		return err
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, callbackErr)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,264 @@
+package pgxload
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Default latency histogram bucket upper bounds used by NewMetrics
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Create a new Metrics collector using the specified latency bucket upper bounds
+// If no buckets are specified, DefaultLatencyBuckets will be used
+func NewMetrics(buckets ...time.Duration) *Metrics {
+
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	sorted := make([]time.Duration, len(buckets))
+	copy(sorted, buckets)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &Metrics{
+		buckets:    sorted,
+		statements: make(map[string]*statementMetrics),
+	}
+}
+
+// In-process collector aggregating query counts, latencies, errors and rows per statement fingerprint
+// Pass to a loader using Config.Metrics, and read it from any exporter using Snapshot
+type Metrics struct {
+	mu         sync.Mutex
+	buckets    []time.Duration
+	statements map[string]*statementMetrics
+}
+
+type statementMetrics struct {
+	count        int64
+	errors       int64
+	rowsScanned  int64
+	rowsAffected int64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+	bucketCounts []int64
+}
+
+// Record a single execution of sql
+func (m *Metrics) Observe(sql string, latency time.Duration, rowsScanned int64, rowsAffected int64, err error) {
+
+	fingerprint := FingerprintSQL(sql)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stmt, ok := m.statements[fingerprint]
+	if !ok {
+		stmt = &statementMetrics{
+			bucketCounts: make([]int64, len(m.buckets)),
+		}
+		m.statements[fingerprint] = stmt
+	}
+
+	stmt.count += 1
+	if err != nil {
+		stmt.errors += 1
+	}
+
+	stmt.rowsScanned += rowsScanned
+	stmt.rowsAffected += rowsAffected
+	stmt.totalLatency += latency
+
+	if latency > stmt.maxLatency {
+		stmt.maxLatency = latency
+	}
+
+	for idx, bound := range m.buckets {
+		if latency <= bound {
+			stmt.bucketCounts[idx] += 1
+		}
+	}
+}
+
+// Take a point in time copy of all collected metrics, sorted by fingerprint
+func (m *Metrics) Snapshot() []StatementMetrics {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make([]StatementMetrics, 0, len(m.statements))
+
+	for fingerprint, stmt := range m.statements {
+
+		buckets := make([]LatencyBucket, len(m.buckets))
+		for idx, bound := range m.buckets {
+			buckets[idx] = LatencyBucket{
+				UpperBound: bound,
+				Count:      stmt.bucketCounts[idx],
+			}
+		}
+
+		snapshot = append(snapshot, StatementMetrics{
+			Fingerprint:    fingerprint,
+			Count:          stmt.count,
+			Errors:         stmt.errors,
+			RowsScanned:    stmt.rowsScanned,
+			RowsAffected:   stmt.rowsAffected,
+			TotalLatency:   stmt.totalLatency,
+			MaxLatency:     stmt.maxLatency,
+			LatencyBuckets: buckets,
+		})
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Fingerprint < snapshot[j].Fingerprint
+	})
+
+	return snapshot
+}
+
+// Discard all collected metrics
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.statements = make(map[string]*statementMetrics)
+}
+
+// Aggregated metrics for one statement fingerprint
+type StatementMetrics struct {
+	Fingerprint  string
+	Count        int64
+	Errors       int64
+	RowsScanned  int64
+	RowsAffected int64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+
+	// Cumulative latency histogram, Count acts as the implicit +Inf bucket
+	LatencyBuckets []LatencyBucket
+}
+
+// Average latency of all observed executions
+func (s StatementMetrics) MeanLatency() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.TotalLatency / time.Duration(s.Count)
+}
+
+// A single cumulative histogram bucket
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
+var fingerprintInListRe = regexp.MustCompile(`\bin ?\(\s*(\?|null)(\s*,\s*(\?|null))*\s*\)`)
+var fingerprintValuesRe = regexp.MustCompile(`\bvalues ?\(\s*(\?|default|null)(\s*,\s*(\?|default|null))*\s*\)(\s*,\s*\(\s*(\?|default|null)(\s*,\s*(\?|default|null))*\s*\))*`)
+
+// Normalize SQL to a fingerprint shared by all executions of the same statement
+// Literals and positional parameters become ?, whitespace and comments are collapsed,
+// keywords are lower cased and IN lists and VALUES lists (e.g. multi row inserts) are collapsed to a single (?).
+// Other parenthesised lists, such as function arguments, are kept
+func FingerprintSQL(sql string) string {
+
+	var out strings.Builder
+	runes := []rune(sql)
+
+	lastSpace := true
+	writeSpace := func() {
+		if !lastSpace {
+			out.WriteRune(' ')
+			lastSpace = true
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			writeSpace()
+			continue
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			writeSpace()
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i++
+			writeSpace()
+			continue
+		case r == '\'':
+			i++
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			out.WriteRune('?')
+		case r == '"':
+			out.WriteRune(r)
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				out.WriteRune(runes[i])
+				i++
+			}
+			if i < len(runes) {
+				out.WriteRune('"')
+			}
+		case r == '$' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			for i+1 < len(runes) && unicode.IsDigit(runes[i+1]) {
+				i++
+			}
+			out.WriteRune('?')
+		case unicode.IsDigit(r):
+			for i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.') {
+				i++
+			}
+			out.WriteRune('?')
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				out.WriteRune(unicode.ToLower(runes[i]))
+				i++
+			}
+			i--
+		default:
+			out.WriteRune(r)
+		}
+
+		lastSpace = false
+	}
+
+	fingerprint := strings.TrimSpace(out.String())
+	fingerprint = fingerprintInListRe.ReplaceAllString(fingerprint, "in (?)")
+	fingerprint = fingerprintValuesRe.ReplaceAllString(fingerprint, "values (?)")
+
+	return fingerprint
+}
@@ -0,0 +1,48 @@
+package pgxload
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprintSQL(t *testing.T) {
+
+	assert.Equal(t, "select * from users where id = ?", FingerprintSQL("SELECT *\n  FROM users WHERE id = $1"))
+	assert.Equal(t, "select * from users where id = ?", FingerprintSQL("select * from users where id = 42 -- lookup"))
+	assert.Equal(t, "select * from users where name = ?", FingerprintSQL("select * from users where name = 'it''s'"))
+	assert.Equal(t, `select "Name" from users where id in (?)`, FingerprintSQL(`select "Name" from users where id in (1, 2, 3)`))
+	assert.Equal(t, `insert into t ("a", "b") values (?)`, FingerprintSQL(`INSERT INTO t ("a", "b") VALUES ($1, DEFAULT), ($2, NULL), ($3, $4)`))
+	assert.Equal(t, "select col1 from t2", FingerprintSQL("select col1 /* comment */ from t2"))
+	assert.Equal(t, "select coalesce(?, ?) from t where id in (?)", FingerprintSQL("select coalesce($1, $2) from t where id IN ($3, $4)"))
+}
+
+func TestMetrics_Snapshot(t *testing.T) {
+
+	m := NewMetrics(10*time.Millisecond, time.Millisecond)
+
+	m.Observe("select * from users where id = $1", 500*time.Microsecond, 1, 0, nil)
+	m.Observe("SELECT * FROM users WHERE id = 7", 5*time.Millisecond, 0, 0, errors.New("boom"))
+	m.Observe("delete from users", 20*time.Millisecond, 0, 3, nil)
+
+	snapshot := m.Snapshot()
+	if assert.Equal(t, 2, len(snapshot)) {
+		assert.Equal(t, "delete from users", snapshot[0].Fingerprint)
+		assert.Equal(t, int64(3), snapshot[0].RowsAffected)
+		assert.Equal(t, int64(0), snapshot[0].LatencyBuckets[1].Count)
+
+		sel := snapshot[1]
+		assert.Equal(t, int64(2), sel.Count)
+		assert.Equal(t, int64(1), sel.Errors)
+		assert.Equal(t, int64(1), sel.RowsScanned)
+		assert.Equal(t, 5*time.Millisecond, sel.MaxLatency)
+		assert.Equal(t, time.Millisecond, sel.LatencyBuckets[0].UpperBound)
+		assert.Equal(t, int64(1), sel.LatencyBuckets[0].Count)
+		assert.Equal(t, int64(2), sel.LatencyBuckets[1].Count)
+	}
+
+	m.Reset()
+	assert.Equal(t, 0, len(m.Snapshot()))
+}
@@ -0,0 +1,374 @@
+// Package pgxloadtest provides a scriptable in-memory PGXConn, so code taking a pgxload.PgxLoader can be tested offline
+package pgxloadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+// Create a new fake connection with no expectations
+func NewConn() *Conn {
+	return &Conn{
+		connInfo: pgtype.NewConnInfo(),
+	}
+}
+
+// A fake pgxload.PGXConn which serves calls from a script of expectations, in order
+// Queries return real pgx.Rows in the text wire format, so pgxload.Scanner decodes them as it would from Postgres
+type Conn struct {
+	mu           sync.Mutex
+	connInfo     *pgtype.ConnInfo
+	expectations []*Expectation
+	statements   []string
+	copied       [][]interface{}
+}
+
+func (c *Conn) expect(e *Expectation) *Expectation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expectations = append(c.expectations, e)
+	return e
+}
+
+// Expect a Query or QueryRow with exactly this SQL, ignoring whitespace differences
+func (c *Conn) ExpectQuery(sql string) *Expectation {
+	return c.expect(&Expectation{kind: expectQuery, sql: sql, anyArgs: true})
+}
+
+// Expect a Query or QueryRow with SQL matching the regular expression
+func (c *Conn) ExpectQueryRegex(pattern string) *Expectation {
+	return c.expect(&Expectation{kind: expectQuery, sqlRe: regexp.MustCompile(pattern), anyArgs: true})
+}
+
+// Expect an Exec with exactly this SQL, ignoring whitespace differences
+func (c *Conn) ExpectExec(sql string) *Expectation {
+	return c.expect(&Expectation{kind: expectExec, sql: sql, anyArgs: true})
+}
+
+// Expect an Exec with SQL matching the regular expression
+func (c *Conn) ExpectExecRegex(pattern string) *Expectation {
+	return c.expect(&Expectation{kind: expectExec, sqlRe: regexp.MustCompile(pattern), anyArgs: true})
+}
+
+// Expect a transaction (or savepoint, when called on a transaction) to begin
+func (c *Conn) ExpectBegin() *Expectation {
+	return c.expect(&Expectation{kind: expectBegin})
+}
+
+// Expect a transaction to commit
+func (c *Conn) ExpectCommit() *Expectation {
+	return c.expect(&Expectation{kind: expectCommit})
+}
+
+// Expect a transaction to roll back
+func (c *Conn) ExpectRollback() *Expectation {
+	return c.expect(&Expectation{kind: expectRollback})
+}
+
+// Expect a CopyFrom into table, which may be schema qualified (e.g. public.users)
+// If columns are specified, the copy must be for exactly those columns
+func (c *Conn) ExpectCopyFrom(table string, columns ...string) *Expectation {
+	e := &Expectation{kind: expectCopyFrom, sql: table, anyArgs: len(columns) == 0}
+	for _, column := range columns {
+		e.args = append(e.args, column)
+	}
+
+	return c.expect(e)
+}
+
+// Returns an error describing any expectations which haven't been triggered
+func (c *Conn) ExpectationsWereMet() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var unmet []string
+	for _, e := range c.expectations {
+		if !e.triggered {
+			unmet = append(unmet, e.describe())
+		}
+	}
+
+	if len(unmet) > 0 {
+		return errors.New("unmet expectations: " + strings.Join(unmet, ", "))
+	}
+
+	return nil
+}
+
+// All SQL statements received by Exec, Query and QueryRow, in order
+func (c *Conn) Statements() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.statements...)
+}
+
+// All rows received by CopyFrom, in order
+func (c *Conn) CopiedRows() [][]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([][]interface{}(nil), c.copied...)
+}
+
+// Match the call against the next untriggered expectation
+func (c *Conn) next(kind expectationKind, sql string, args []interface{}) (*Expectation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if kind == expectQuery || kind == expectExec {
+		c.statements = append(c.statements, sql)
+	}
+
+	for _, e := range c.expectations {
+		if e.triggered {
+			continue
+		}
+
+		if err := e.matches(kind, sql, args); err != nil {
+			return nil, err
+		}
+
+		e.triggered = true
+		return e, nil
+	}
+
+	if len(sql) > 0 {
+		return nil, fmt.Errorf("unexpected %s %q", kind, sql)
+	}
+
+	return nil, fmt.Errorf("unexpected %s", kind)
+}
+
+func (c *Conn) Begin(ctx context.Context) (pgx.Tx, error) {
+	return c.BeginTx(ctx, pgx.TxOptions{})
+}
+
+func (c *Conn) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	e, err := c.next(expectBegin, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return &tx{conn: c}, nil
+}
+
+func (c *Conn) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	e, err := c.next(expectExec, sql, arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return e.tag, nil
+}
+
+func (c *Conn) Query(ctx context.Context, sql string, optionsAndArgs ...interface{}) (pgx.Rows, error) {
+	r, err := c.query(sql, optionsAndArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (c *Conn) QueryRow(ctx context.Context, sql string, optionsAndArgs ...interface{}) pgx.Row {
+	r, err := c.query(sql, optionsAndArgs)
+	return &row{rows: r, err: err}
+}
+
+func (c *Conn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	columns := make([]interface{}, len(columnNames))
+	for idx, column := range columnNames {
+		columns[idx] = column
+	}
+
+	e, err := c.next(expectCopyFrom, strings.Join(tableName, "."), columns)
+	if err != nil {
+		return 0, err
+	}
+
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	var copied [][]interface{}
+	for rowSrc.Next() {
+		values, err := rowSrc.Values()
+		if err != nil {
+			return 0, err
+		}
+
+		if len(values) != len(columnNames) {
+			return 0, fmt.Errorf("copy row has %d values, expected %d", len(values), len(columnNames))
+		}
+
+		copied = append(copied, values)
+	}
+
+	if err := rowSrc.Err(); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.copied = append(c.copied, copied...)
+	c.mu.Unlock()
+
+	return int64(len(copied)), nil
+}
+
+func (c *Conn) query(sql string, args []interface{}) (*rows, error) {
+	e, err := c.next(expectQuery, sql, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	result := e.rows
+	if result == nil {
+		result = NewRows()
+	}
+
+	return result.build(c.connInfo)
+}
+
+// A fake pgx.Tx, serving calls from the expectations of the Conn it was started from
+type tx struct {
+	conn   *Conn
+	closed bool
+}
+
+var errUnsupported = errors.New("not supported by pgxloadtest")
+
+func (t *tx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if t.closed {
+		return nil, pgx.ErrTxClosed
+	}
+
+	return t.conn.Begin(ctx)
+}
+
+func (t *tx) Commit(ctx context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+
+	t.closed = true
+
+	e, err := t.conn.next(expectCommit, "", nil)
+	if err != nil {
+		return err
+	}
+
+	return e.err
+}
+
+func (t *tx) Rollback(ctx context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+
+	t.closed = true
+
+	e, err := t.conn.next(expectRollback, "", nil)
+	if err != nil {
+		return err
+	}
+
+	return e.err
+}
+
+func (t *tx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	if t.closed {
+		return 0, pgx.ErrTxClosed
+	}
+
+	return t.conn.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+// Batches aren't supported, every result of the returned BatchResults fails with an error
+func (t *tx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return unsupportedBatchResults{}
+}
+
+// Large object calls run their lo_* queries in the transaction, so they can be scripted with ExpectQuery
+// pgx has no way to create LargeObjects for another Tx, so its unexported tx field is set directly
+func (t *tx) LargeObjects() pgx.LargeObjects {
+	var lo pgx.LargeObjects
+
+	field := reflect.ValueOf(&lo).Elem().FieldByName("tx")
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(pgx.Tx(t)))
+
+	return lo
+}
+
+type unsupportedBatchResults struct{}
+
+func (unsupportedBatchResults) Exec() (pgconn.CommandTag, error) {
+	return nil, errUnsupported
+}
+
+func (unsupportedBatchResults) Query() (pgx.Rows, error) {
+	return nil, errUnsupported
+}
+
+func (unsupportedBatchResults) QueryRow() pgx.Row {
+	return &row{err: errUnsupported}
+}
+
+func (unsupportedBatchResults) Close() error {
+	return nil
+}
+
+func (t *tx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	return nil, errUnsupported
+}
+
+func (t *tx) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	if t.closed {
+		return nil, pgx.ErrTxClosed
+	}
+
+	return t.conn.Exec(ctx, sql, arguments...)
+}
+
+func (t *tx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if t.closed {
+		return nil, pgx.ErrTxClosed
+	}
+
+	return t.conn.Query(ctx, sql, args...)
+}
+
+func (t *tx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if t.closed {
+		return &row{err: pgx.ErrTxClosed}
+	}
+
+	return t.conn.QueryRow(ctx, sql, args...)
+}
+
+func (t *tx) Conn() *pgx.Conn {
+	return nil
+}
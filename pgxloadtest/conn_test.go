@@ -0,0 +1,135 @@
+package pgxloadtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload"
+)
+
+var _ pgxload.PGXConn = (*Conn)(nil)
+
+type user struct {
+	ID        int64
+	Name      string
+	Nickname  *string
+	CreatedAt time.Time
+}
+
+func TestConn_Scanner(t *testing.T) {
+
+	ctx := context.Background()
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	conn := NewConn()
+	conn.ExpectQuery("select * from users where id > $1").
+		WithArgs(0).
+		WillReturnRows(NewRows("id", "name", "nickname", "created_at").
+			AddRow(int64(1), "Alice", nil, created).
+			AddRow(int64(2), "Bob", "bobby", created))
+
+	loader, err := pgxload.NewPgxLoader(conn)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rows, err := loader.Query(ctx, "select *\n from users where id > $1", 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var users []user
+	if assert.NoError(t, loader.Scanner(rows).Scan(&users)) && assert.Equal(t, 2, len(users)) {
+		assert.Equal(t, "Alice", users[0].Name)
+		assert.Nil(t, users[0].Nickname)
+		assert.True(t, created.Equal(users[0].CreatedAt))
+		assert.Equal(t, int64(2), users[1].ID)
+		assert.Equal(t, "bobby", *users[1].Nickname)
+	}
+
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestConn_Transaction(t *testing.T) {
+
+	ctx := context.Background()
+	failure := errors.New("failure")
+
+	conn := NewConn()
+	conn.ExpectBegin()
+	conn.ExpectExec("update users set name = $1").WillReturnResult("UPDATE 2")
+	conn.ExpectCommit()
+	conn.ExpectBegin()
+	conn.ExpectExecRegex(`^delete from users`).WillReturnError(failure)
+	conn.ExpectRollback()
+
+	loader, _ := pgxload.NewPgxLoader(conn)
+
+	err := pgxload.RunInTransaction(ctx, loader, func(ctx context.Context, tx pgxload.PgxTxLoader) error {
+		tag, err := tx.Exec(ctx, "update users set name = $1", "x")
+		assert.Equal(t, int64(2), tag.RowsAffected())
+		return err
+	})
+	assert.NoError(t, err)
+
+	err = pgxload.RunInTransaction(ctx, loader, func(ctx context.Context, tx pgxload.PgxTxLoader) error {
+		_, err := tx.Exec(ctx, "delete from users")
+		return err
+	})
+	assert.Equal(t, failure, err)
+
+	assert.NoError(t, conn.ExpectationsWereMet())
+	assert.Equal(t, []string{"update users set name = $1", "delete from users"}, conn.Statements())
+}
+
+func TestConn_Unexpected(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := NewConn()
+	conn.ExpectQuery("select 1")
+
+	_, err := conn.Exec(ctx, "select 1")
+	assert.Error(t, err)
+
+	assert.Error(t, conn.ExpectationsWereMet())
+
+	err = conn.QueryRow(ctx, "select 1").Scan()
+	assert.Equal(t, pgx.ErrNoRows, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestConn_TxBatchAndLargeObjects(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := NewConn()
+	conn.ExpectBegin()
+	conn.ExpectQuery("select lo_create($1)").WithArgs(uint32(0)).WillReturnRows(NewRows("lo_create").AddRow(uint32(42)))
+	conn.ExpectRollback()
+
+	tx, err := conn.Begin(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	results := tx.SendBatch(ctx, &pgx.Batch{})
+	_, err = results.Exec()
+	assert.Error(t, err)
+	_, err = results.Query()
+	assert.Error(t, err)
+	assert.Error(t, results.QueryRow().Scan())
+	assert.NoError(t, results.Close())
+
+	lo := tx.LargeObjects()
+	oid, err := lo.Create(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(42), oid)
+
+	assert.NoError(t, tx.Rollback(ctx))
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,143 @@
+package pgxloadtest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+type expectationKind int
+
+const (
+	expectQuery expectationKind = iota
+	expectExec
+	expectBegin
+	expectCommit
+	expectRollback
+	expectCopyFrom
+)
+
+func (k expectationKind) String() string {
+	switch k {
+	case expectQuery:
+		return "query"
+	case expectExec:
+		return "exec"
+	case expectBegin:
+		return "begin"
+	case expectCommit:
+		return "commit"
+	case expectRollback:
+		return "rollback"
+	case expectCopyFrom:
+		return "copy from"
+	}
+
+	return "unknown"
+}
+
+// A single expected call, configured with the With* and Will* funcs
+type Expectation struct {
+	kind expectationKind
+
+	sql     string
+	sqlRe   *regexp.Regexp
+	args    []interface{}
+	anyArgs bool
+
+	rows *Rows
+	tag  pgconn.CommandTag
+	err  error
+
+	triggered bool
+}
+
+// Only match calls with exactly these arguments
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args = args
+	e.anyArgs = false
+	return e
+}
+
+// Return rows from a matching query
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// Return the command tag (e.g. "UPDATE 2") from a matching exec
+func (e *Expectation) WillReturnResult(tag string) *Expectation {
+	e.tag = pgconn.CommandTag(tag)
+	return e
+}
+
+// Fail a matching call with err
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) matches(kind expectationKind, sql string, args []interface{}) error {
+
+	if e.kind != kind {
+		return fmt.Errorf("expected %s, got %s", e.describe(), kind)
+	}
+
+	if kind == expectCopyFrom {
+		if len(e.sql) > 0 && e.sql != sql {
+			return fmt.Errorf("expected %s, got %s %q", e.describe(), kind, sql)
+		}
+
+		if !e.anyArgs && !reflect.DeepEqual(normalizeArgs(e.args), normalizeArgs(args)) {
+			return fmt.Errorf("%s columns %v do not match expected %v", kind, args, e.args)
+		}
+
+		return nil
+	}
+
+	if kind != expectQuery && kind != expectExec {
+		return nil
+	}
+
+	if e.sqlRe != nil {
+		if !e.sqlRe.MatchString(sql) {
+			return fmt.Errorf("expected %s, got %s %q", e.describe(), kind, sql)
+		}
+	} else if normalizeSQL(e.sql) != normalizeSQL(sql) {
+		return fmt.Errorf("expected %s, got %s %q", e.describe(), kind, sql)
+	}
+
+	if !e.anyArgs && !reflect.DeepEqual(normalizeArgs(e.args), normalizeArgs(args)) {
+		return fmt.Errorf("%s arguments %v do not match expected %v", kind, args, e.args)
+	}
+
+	return nil
+}
+
+func (e *Expectation) describe() string {
+	if e.sqlRe != nil {
+		return fmt.Sprintf("%s matching %q", e.kind, e.sqlRe.String())
+	}
+
+	if len(e.sql) > 0 {
+		return fmt.Sprintf("%s %q", e.kind, e.sql)
+	}
+
+	return e.kind.String()
+}
+
+// Collapse whitespace so exact expectations aren't sensitive to formatting
+func normalizeSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+func normalizeArgs(args []interface{}) []interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+
+	return args
+}
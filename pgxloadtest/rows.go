@@ -0,0 +1,355 @@
+package pgxloadtest
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+// Create a new canned result set with the specified column names
+// Column types are inferred from the first non-nil value of each column, unless set with WithTypes
+func NewRows(columns ...string) *Rows {
+	return &Rows{
+		columns: columns,
+		types:   make([]string, len(columns)),
+	}
+}
+
+// A canned result set, returned by queries matching an expectation
+type Rows struct {
+	columns []string
+	types   []string
+	values  [][]interface{}
+
+	errAt  int
+	rowErr error
+}
+
+// Explicitly set the Postgres type names (e.g. int8, text, timestamptz) of each column
+func (r *Rows) WithTypes(typeNames ...string) *Rows {
+	copy(r.types, typeNames)
+	return r
+}
+
+// Add a row of values, one per column
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	r.values = append(r.values, values)
+	return r
+}
+
+// Fail with err when reading the row at the specified index, ending the result set
+func (r *Rows) RowError(row int, err error) *Rows {
+	r.errAt = row
+	r.rowErr = err
+	return r
+}
+
+// Encode the result set into the text wire format, producing pgx.Rows which decode exactly like a real connection
+func (r *Rows) build(ci *pgtype.ConnInfo) (*rows, error) {
+
+	fieldDescriptions, dataTypes, err := r.describe(ci)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := r.encode(ci, dataTypes, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	errAt := -1
+	if r.rowErr != nil {
+		errAt = r.errAt
+	}
+
+	return &rows{
+		connInfo:          ci,
+		fieldDescriptions: fieldDescriptions,
+		values:            encoded,
+		current:           -1,
+		errAt:             errAt,
+		rowErr:            r.rowErr,
+	}, nil
+}
+
+// Resolve the type of each column, returning text format field descriptions
+func (r *Rows) describe(ci *pgtype.ConnInfo) ([]pgproto3.FieldDescription, []*pgtype.DataType, error) {
+
+	fieldDescriptions := make([]pgproto3.FieldDescription, len(r.columns))
+	dataTypes := make([]*pgtype.DataType, len(r.columns))
+
+	for idx, column := range r.columns {
+		typeName := r.types[idx]
+
+		if len(typeName) == 0 {
+			typeName = "text"
+			for _, row := range r.values {
+				if idx < len(row) && row[idx] != nil {
+					typeName = typeNameForValue(row[idx])
+					break
+				}
+			}
+		}
+
+		dt, ok := ci.DataTypeForName(typeName)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown type %s for column %s", typeName, column)
+		}
+
+		dataTypes[idx] = dt
+		fieldDescriptions[idx] = pgproto3.FieldDescription{
+			Name:         []byte(column),
+			DataTypeOID:  dt.OID,
+			DataTypeSize: -1,
+			TypeModifier: -1,
+			Format:       pgx.TextFormatCode,
+		}
+	}
+
+	return fieldDescriptions, dataTypes, nil
+}
+
+// Encode every row, using the format code of each column (text if formats is empty)
+func (r *Rows) encode(ci *pgtype.ConnInfo, dataTypes []*pgtype.DataType, formats []int16) ([][][]byte, error) {
+
+	encoded := make([][][]byte, len(r.values))
+	for rowIdx, row := range r.values {
+		if len(row) != len(r.columns) {
+			return nil, fmt.Errorf("row %d has %d values, expected %d", rowIdx, len(row), len(r.columns))
+		}
+
+		encoded[rowIdx] = make([][]byte, len(row))
+		for idx, value := range row {
+			format := int16(pgx.TextFormatCode)
+			if idx < len(formats) {
+				format = formats[idx]
+			}
+
+			buf, err := encodeValue(ci, dataTypes[idx], value, format)
+			if err != nil {
+				return nil, fmt.Errorf("row %d column %s: %w", rowIdx, r.columns[idx], err)
+			}
+
+			encoded[rowIdx][idx] = buf
+		}
+	}
+
+	return encoded, nil
+}
+
+func typeNameForValue(value interface{}) string {
+
+	switch value.(type) {
+	case bool:
+		return "bool"
+	case int16, int8, uint8:
+		return "int2"
+	case int32, uint16:
+		return "int4"
+	case int, int64, uint32, uint64, uint:
+		return "int8"
+	case float32:
+		return "float4"
+	case float64:
+		return "float8"
+	case []byte:
+		return "bytea"
+	case time.Time:
+		return "timestamptz"
+	}
+
+	return "text"
+}
+
+func encodeValue(ci *pgtype.ConnInfo, dt *pgtype.DataType, value interface{}, format int16) ([]byte, error) {
+
+	if valuer, ok := value.(driver.Valuer); ok {
+		var err error
+		value, err = valuer.Value()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	pgValue := reflect.New(reflect.ValueOf(dt.Value).Elem().Type()).Interface().(pgtype.Value)
+
+	if err := pgValue.Set(value); err != nil {
+		if dt.Name != "text" {
+			return nil, err
+		}
+
+		// Anything can be text, fall back to its default string format
+		if err := pgValue.Set(fmt.Sprint(value)); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf []byte
+	var err error
+
+	if format == pgx.BinaryFormatCode {
+		encoder, ok := pgValue.(pgtype.BinaryEncoder)
+		if !ok {
+			return nil, errors.New("type " + dt.Name + " can't be encoded as binary")
+		}
+
+		buf, err = encoder.EncodeBinary(ci, nil)
+	} else {
+		encoder, ok := pgValue.(pgtype.TextEncoder)
+		if !ok {
+			return nil, errors.New("type " + dt.Name + " can't be encoded as text")
+		}
+
+		buf, err = encoder.EncodeText(ci, nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if buf == nil {
+		// Empty values (e.g. an empty string) must stay distinct from NULL
+		buf = []byte{}
+	}
+
+	return buf, nil
+}
+
+// pgx.Rows implementation over encoded canned values
+type rows struct {
+	connInfo          *pgtype.ConnInfo
+	fieldDescriptions []pgproto3.FieldDescription
+	values            [][][]byte
+
+	current int
+	scanned int64
+	closed  bool
+	err     error
+
+	errAt  int
+	rowErr error
+}
+
+func (r *rows) Close() {
+	r.closed = true
+}
+
+func (r *rows) Err() error {
+	return r.err
+}
+
+func (r *rows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag(fmt.Sprintf("SELECT %d", r.scanned))
+}
+
+func (r *rows) FieldDescriptions() []pgproto3.FieldDescription {
+	return r.fieldDescriptions
+}
+
+func (r *rows) Next() bool {
+	if r.closed {
+		return false
+	}
+
+	r.current += 1
+
+	if r.current == r.errAt {
+		r.err = r.rowErr
+		r.Close()
+		return false
+	}
+
+	if r.current >= len(r.values) {
+		r.Close()
+		return false
+	}
+
+	r.scanned += 1
+	return true
+}
+
+func (r *rows) Scan(dest ...interface{}) error {
+	if r.closed || r.current < 0 {
+		return errors.New("no current row to scan")
+	}
+
+	err := pgx.ScanRow(r.connInfo, r.fieldDescriptions, r.values[r.current], dest...)
+	if err != nil {
+		r.err = err
+		r.Close()
+	}
+
+	return err
+}
+
+func (r *rows) Values() ([]interface{}, error) {
+	if r.closed || r.current < 0 {
+		return nil, errors.New("no current row")
+	}
+
+	values := make([]interface{}, len(r.fieldDescriptions))
+	for idx, fd := range r.fieldDescriptions {
+		buf := r.values[r.current][idx]
+		if buf == nil {
+			continue
+		}
+
+		dt, _ := r.connInfo.DataTypeForOID(fd.DataTypeOID)
+		value := reflect.New(reflect.ValueOf(dt.Value).Elem().Type()).Interface().(pgtype.Value)
+
+		decoder, ok := value.(pgtype.TextDecoder)
+		if !ok {
+			decoder = &pgtype.GenericText{}
+		}
+
+		if err := decoder.DecodeText(r.connInfo, buf); err != nil {
+			return nil, err
+		}
+
+		values[idx] = decoder.(pgtype.Value).Get()
+	}
+
+	return values, nil
+}
+
+func (r *rows) RawValues() [][]byte {
+	if r.current < 0 || r.current >= len(r.values) {
+		return nil
+	}
+
+	return r.values[r.current]
+}
+
+// pgx.Row implementation, reading the first row of a result set
+type row struct {
+	rows *rows
+	err  error
+}
+
+func (r *row) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if r.rows.Err() != nil {
+			return r.rows.Err()
+		}
+		return pgx.ErrNoRows
+	}
+
+	return r.rows.Scan(dest...)
+}
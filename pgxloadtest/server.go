@@ -0,0 +1,812 @@
+package pgxloadtest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+// Create and start a new fake Postgres server, listening on a local TCP socket
+// Connect to it with pgx.Connect(ctx, server.ConnString())
+func NewServer() (*Server, error) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		connInfo: pgtype.NewConnInfo(),
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// A tiny Postgres wire protocol stand-in, serving canned results over the simple and extended query protocols
+// Transaction control statements (BEGIN, COMMIT, ROLLBACK, SAVEPOINT, RELEASE, SET) succeed by default,
+// every other statement must match a handler. COPY (query) TO STDOUT is served from the handler for query,
+// in the text or csv format
+type Server struct {
+	listener net.Listener
+	connInfo *pgtype.ConnInfo
+	wg       sync.WaitGroup
+
+	mu         sync.Mutex
+	handlers   []*Handler
+	statements []Statement
+	conns      map[net.Conn]struct{}
+	closed     bool
+}
+
+// A statement received by the server, with its decoded arguments
+type Statement struct {
+	SQL  string
+	Args []interface{}
+}
+
+// A canned response to statements matching SQL, configured with the With* and Will* funcs
+type Handler struct {
+	sql        string
+	sqlRe      *regexp.Regexp
+	paramTypes []string
+
+	rows *Rows
+	tag  string
+	err  *pgconn.PgError
+}
+
+// Set the Postgres type names of the statement parameters, as the server can't infer them like Postgres does
+// Parameters without a type use their cast in the SQL (e.g. $1::int8), or otherwise text
+func (h *Handler) WithParamTypes(typeNames ...string) *Handler {
+	h.paramTypes = typeNames
+	return h
+}
+
+// Return rows from matching statements
+func (h *Handler) WillReturnRows(rows *Rows) *Handler {
+	h.rows = rows
+	return h
+}
+
+// Return the command tag (e.g. "INSERT 0 2") from matching statements
+func (h *Handler) WillReturnResult(tag string) *Handler {
+	h.tag = tag
+	return h
+}
+
+// Fail matching statements with err
+func (h *Handler) WillReturnError(err *pgconn.PgError) *Handler {
+	h.err = err
+	return h
+}
+
+func (h *Handler) matches(sql string) bool {
+	if h.sqlRe != nil {
+		return h.sqlRe.MatchString(sql)
+	}
+
+	return normalizeSQL(h.sql) == normalizeSQL(sql)
+}
+
+// Handle statements with exactly this SQL, ignoring whitespace differences
+func (s *Server) Handle(sql string) *Handler {
+	return s.handle(&Handler{sql: sql})
+}
+
+// Handle statements with SQL matching the regular expression
+func (s *Server) HandleRegex(pattern string) *Handler {
+	return s.handle(&Handler{sqlRe: regexp.MustCompile(pattern)})
+}
+
+func (s *Server) handle(h *Handler) *Handler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers = append(s.handlers, h)
+	return h
+}
+
+// A pgx connection string for the server
+func (s *Server) ConnString() string {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return fmt.Sprintf("host=%s port=%d user=pgxloadtest database=pgxloadtest sslmode=disable", addr.IP.String(), addr.Port)
+}
+
+// All statements executed by the server, in order
+func (s *Server) Statements() []Statement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Statement(nil), s.statements...)
+}
+
+// Stop listening and close all open connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.listener.Close()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				_ = conn.Close()
+			}()
+
+			sc := &serverConn{
+				server:     s,
+				backend:    pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn),
+				conn:       conn,
+				statements: make(map[string]*preparedStatement),
+				portals:    make(map[string]*portal),
+				txStatus:   'I',
+			}
+
+			_ = sc.run()
+		}()
+	}
+}
+
+// Find the handler for sql, nil if sql is a transaction control statement handled by default
+func (s *Server) handlerFor(sql string) (*Handler, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, h := range s.handlers {
+		if h.matches(sql) {
+			return h, nil
+		}
+	}
+
+	if len(defaultCommandTag(sql)) > 0 {
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("pgxloadtest: no handler for statement %q", sql)
+}
+
+func (s *Server) record(sql string, args []interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statements = append(s.statements, Statement{SQL: sql, Args: args})
+}
+
+var defaultCommands = map[string]string{
+	"begin":     "BEGIN",
+	"start":     "BEGIN",
+	"commit":    "COMMIT",
+	"end":       "COMMIT",
+	"rollback":  "ROLLBACK",
+	"abort":     "ROLLBACK",
+	"savepoint": "SAVEPOINT",
+	"release":   "RELEASE",
+	"set":       "SET",
+}
+
+func defaultCommandTag(sql string) string {
+	fields := strings.Fields(strings.ToLower(sql))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return defaultCommands[strings.TrimSuffix(fields[0], ";")]
+}
+
+type preparedStatement struct {
+	sql       string
+	handler   *Handler
+	paramOIDs []uint32
+}
+
+type portal struct {
+	stmt          *preparedStatement
+	args          []interface{}
+	resultFormats []int16
+}
+
+type serverConn struct {
+	server     *Server
+	backend    *pgproto3.Backend
+	conn       net.Conn
+	statements map[string]*preparedStatement
+	portals    map[string]*portal
+	txStatus   byte
+
+	// Set after an error in the extended protocol, messages are ignored until the next Sync
+	skipUntilSync bool
+}
+
+func (c *serverConn) run() error {
+
+	if err := c.startup(); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := c.backend.Receive()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := msg.(*pgproto3.Terminate); ok {
+			return nil
+		}
+
+		if _, ok := msg.(*pgproto3.Sync); ok {
+			c.skipUntilSync = false
+			if err := c.backend.Send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if c.skipUntilSync {
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			err = c.simpleQuery(msg.String)
+		case *pgproto3.Parse:
+			err = c.parse(msg)
+		case *pgproto3.Describe:
+			err = c.describe(msg)
+		case *pgproto3.Bind:
+			err = c.bind(msg)
+		case *pgproto3.Execute:
+			err = c.execute(msg)
+		case *pgproto3.Close:
+			if msg.ObjectType == 'S' {
+				delete(c.statements, msg.Name)
+			} else {
+				delete(c.portals, msg.Name)
+			}
+			err = c.backend.Send(&pgproto3.CloseComplete{})
+		case *pgproto3.Flush:
+		default:
+			err = statementError{fmt.Errorf("pgxloadtest: unsupported message %T", msg)}
+		}
+
+		var stmtErr statementError
+		if errors.As(err, &stmtErr) {
+			c.skipUntilSync = true
+			err = c.sendError(stmtErr.err)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// A statement failure to report to the client, rather than a failure of the connection itself
+type statementError struct {
+	err error
+}
+
+func (s statementError) Error() string {
+	return s.err.Error()
+}
+
+func (c *serverConn) startup() error {
+
+	for {
+		msg, err := c.backend.ReceiveStartupMessage()
+		if err != nil {
+			return err
+		}
+
+		switch msg.(type) {
+		case *pgproto3.SSLRequest:
+			if _, err := c.conn.Write([]byte("N")); err != nil {
+				return err
+			}
+			continue
+		case *pgproto3.StartupMessage:
+		default:
+			return fmt.Errorf("pgxloadtest: unexpected startup message %T", msg)
+		}
+
+		break
+	}
+
+	msgs := []pgproto3.BackendMessage{
+		&pgproto3.AuthenticationOk{},
+		&pgproto3.ParameterStatus{Name: "server_version", Value: "12.0"},
+		&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"},
+		&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"},
+		&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1},
+		&pgproto3.ReadyForQuery{TxStatus: c.txStatus},
+	}
+
+	for _, msg := range msgs {
+		if err := c.backend.Send(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *serverConn) sendError(err error) error {
+
+	if c.txStatus == 'T' {
+		c.txStatus = 'E'
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		pgErr = &pgconn.PgError{Severity: "ERROR", Code: "XX000", Message: err.Error()}
+	}
+
+	return c.backend.Send(&pgproto3.ErrorResponse{
+		Severity:       pgErr.Severity,
+		Code:           pgErr.Code,
+		Message:        pgErr.Message,
+		Detail:         pgErr.Detail,
+		Hint:           pgErr.Hint,
+		ConstraintName: pgErr.ConstraintName,
+		TableName:      pgErr.TableName,
+		ColumnName:     pgErr.ColumnName,
+	})
+}
+
+// Track the transaction status through transaction control statements
+func (c *serverConn) trackTxStatus(sql string) {
+	switch defaultCommandTag(sql) {
+	case "BEGIN":
+		c.txStatus = 'T'
+	case "COMMIT":
+		c.txStatus = 'I'
+	case "ROLLBACK":
+		if !strings.Contains(strings.ToLower(sql), " to ") {
+			c.txStatus = 'I'
+		} else if c.txStatus == 'E' {
+			c.txStatus = 'T'
+		}
+	}
+}
+
+func (c *serverConn) simpleQuery(sql string) error {
+
+	if len(strings.TrimSpace(sql)) == 0 {
+		if err := c.backend.Send(&pgproto3.EmptyQueryResponse{}); err != nil {
+			return err
+		}
+		return c.backend.Send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus})
+	}
+
+	c.server.record(sql, nil)
+
+	if match := copyOutRe.FindStringSubmatch(sql); match != nil {
+		err := c.copyOut(match[1], strings.ToLower(match[2]))
+
+		var stmtErr statementError
+		if errors.As(err, &stmtErr) {
+			err = c.sendError(stmtErr.err)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return c.backend.Send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus})
+	}
+
+	h, err := c.server.handlerFor(sql)
+	if err == nil && h != nil && h.err != nil {
+		err = h.err
+	}
+
+	if err != nil {
+		if err := c.sendError(err); err != nil {
+			return err
+		}
+		return c.backend.Send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus})
+	}
+
+	if h != nil && h.rows != nil {
+		fields, _, describeErr := h.rows.describe(c.server.connInfo)
+		if describeErr != nil {
+			err = statementError{describeErr}
+		} else {
+			err = c.backend.Send(&pgproto3.RowDescription{Fields: fields})
+		}
+	}
+
+	if err == nil {
+		err = c.sendResult(sql, h, nil)
+	}
+
+	var stmtErr statementError
+	if errors.As(err, &stmtErr) {
+		err = c.sendError(stmtErr.err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.backend.Send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus})
+}
+
+// Send the data rows and command completion of a statement
+func (c *serverConn) sendResult(sql string, h *Handler, formats []int16) error {
+
+	tag := defaultCommandTag(sql)
+
+	if h != nil {
+		tag = h.tag
+
+		if h.rows != nil {
+			_, dataTypes, err := h.rows.describe(c.server.connInfo)
+			if err != nil {
+				return statementError{err}
+			}
+
+			encoded, err := h.rows.encode(c.server.connInfo, dataTypes, formats)
+			if err != nil {
+				return statementError{err}
+			}
+
+			for _, values := range encoded {
+				if err := c.backend.Send(&pgproto3.DataRow{Values: values}); err != nil {
+					return err
+				}
+			}
+
+			if len(tag) == 0 {
+				tag = fmt.Sprintf("SELECT %d", len(encoded))
+			}
+		}
+	}
+
+	c.trackTxStatus(sql)
+
+	return c.backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+}
+
+var copyOutRe = regexp.MustCompile(`(?is)^\s*copy\s*\((.*)\)\s*to\s+stdout(.*)$`)
+
+// Serve COPY (query) TO STDOUT from the rows of the handler for query, options selects csv and a header
+func (c *serverConn) copyOut(query string, options string) error {
+
+	h, err := c.server.handlerFor(query)
+	if err != nil {
+		return statementError{err}
+	}
+
+	if h == nil || h.rows == nil {
+		return statementError{fmt.Errorf("pgxloadtest: no rows to copy for statement %q", query)}
+	}
+
+	if h.err != nil {
+		return statementError{h.err}
+	}
+
+	_, dataTypes, err := h.rows.describe(c.server.connInfo)
+	if err != nil {
+		return statementError{err}
+	}
+
+	encoded, err := h.rows.encode(c.server.connInfo, dataTypes, nil)
+	if err != nil {
+		return statementError{err}
+	}
+
+	csv := strings.Contains(options, "csv")
+
+	lines := make([][][]byte, 0, len(encoded)+1)
+	if csv && strings.Contains(options, "header") {
+		header := make([][]byte, len(h.rows.columns))
+		for idx, column := range h.rows.columns {
+			header[idx] = []byte(column)
+		}
+		lines = append(lines, header)
+	}
+	lines = append(lines, encoded...)
+
+	formats := make([]uint16, len(h.rows.columns))
+	if err := c.backend.Send(&pgproto3.CopyOutResponse{OverallFormat: 0, ColumnFormatCodes: formats}); err != nil {
+		return err
+	}
+
+	for _, values := range lines {
+		var line []byte
+		if csv {
+			line = copyCSVLine(values)
+		} else {
+			line = copyTextLine(values)
+		}
+
+		if err := c.backend.Send(&pgproto3.CopyData{Data: line}); err != nil {
+			return err
+		}
+	}
+
+	if err := c.backend.Send(&pgproto3.CopyDone{}); err != nil {
+		return err
+	}
+
+	return c.backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(fmt.Sprintf("COPY %d", len(encoded)))})
+}
+
+var copyTextReplacer = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// A row in the COPY text format, with \N for NULL
+func copyTextLine(values [][]byte) []byte {
+
+	fields := make([]string, len(values))
+	for idx, value := range values {
+		if value == nil {
+			fields[idx] = `\N`
+		} else {
+			fields[idx] = copyTextReplacer.Replace(string(value))
+		}
+	}
+
+	return []byte(strings.Join(fields, "\t") + "\n")
+}
+
+// A row in the COPY csv format, with an unquoted empty value for NULL
+func copyCSVLine(values [][]byte) []byte {
+
+	fields := make([]string, len(values))
+	for idx, value := range values {
+		switch {
+		case value == nil:
+		case len(value) == 0 || strings.ContainsAny(string(value), ",\"\r\n"):
+			fields[idx] = `"` + strings.Replace(string(value), `"`, `""`, -1) + `"`
+		default:
+			fields[idx] = string(value)
+		}
+	}
+
+	return []byte(strings.Join(fields, ",") + "\n")
+}
+
+var paramRe = regexp.MustCompile(`\$(\d+)(::([A-Za-z0-9_]+)(\[\])?)?`)
+
+func (c *serverConn) parse(msg *pgproto3.Parse) error {
+
+	h, err := c.server.handlerFor(msg.Query)
+	if err != nil {
+		return statementError{err}
+	}
+
+	paramCount := 0
+	casts := make(map[int]string)
+	for _, match := range paramRe.FindAllStringSubmatch(msg.Query, -1) {
+		num, _ := strconv.Atoi(match[1])
+		if num > paramCount {
+			paramCount = num
+		}
+
+		if len(match[3]) > 0 {
+			typeName := strings.ToLower(match[3])
+			if len(match[4]) > 0 {
+				typeName = "_" + typeName
+			}
+			casts[num-1] = typeName
+		}
+	}
+
+	paramOIDs := make([]uint32, paramCount)
+	for idx := range paramOIDs {
+		if idx < len(msg.ParameterOIDs) && msg.ParameterOIDs[idx] != 0 {
+			paramOIDs[idx] = msg.ParameterOIDs[idx]
+			continue
+		}
+
+		typeName := "text"
+		if h != nil && idx < len(h.paramTypes) && len(h.paramTypes[idx]) > 0 {
+			typeName = h.paramTypes[idx]
+		} else if cast, ok := casts[idx]; ok {
+			typeName = cast
+		}
+
+		dt, ok := c.server.connInfo.DataTypeForName(typeName)
+		if !ok {
+			return statementError{fmt.Errorf("pgxloadtest: unknown parameter type %s", typeName)}
+		}
+
+		paramOIDs[idx] = dt.OID
+	}
+
+	c.statements[msg.Name] = &preparedStatement{
+		sql:       msg.Query,
+		handler:   h,
+		paramOIDs: paramOIDs,
+	}
+
+	return c.backend.Send(&pgproto3.ParseComplete{})
+}
+
+func (c *serverConn) describe(msg *pgproto3.Describe) error {
+
+	var stmt *preparedStatement
+	var formats []int16
+
+	if msg.ObjectType == 'S' {
+		stmt = c.statements[msg.Name]
+		if stmt == nil {
+			return statementError{fmt.Errorf("pgxloadtest: unknown prepared statement %q", msg.Name)}
+		}
+
+		if err := c.backend.Send(&pgproto3.ParameterDescription{ParameterOIDs: stmt.paramOIDs}); err != nil {
+			return err
+		}
+	} else {
+		p := c.portals[msg.Name]
+		if p == nil {
+			return statementError{fmt.Errorf("pgxloadtest: unknown portal %q", msg.Name)}
+		}
+
+		stmt = p.stmt
+		formats = p.resultFormats
+	}
+
+	if stmt.handler == nil || stmt.handler.rows == nil {
+		return c.backend.Send(&pgproto3.NoData{})
+	}
+
+	fields, _, err := stmt.handler.rows.describe(c.server.connInfo)
+	if err != nil {
+		return statementError{err}
+	}
+
+	for idx := range fields {
+		if idx < len(formats) {
+			fields[idx].Format = formats[idx]
+		}
+	}
+
+	return c.backend.Send(&pgproto3.RowDescription{Fields: fields})
+}
+
+func (c *serverConn) bind(msg *pgproto3.Bind) error {
+
+	stmt := c.statements[msg.PreparedStatement]
+	if stmt == nil {
+		return statementError{fmt.Errorf("pgxloadtest: unknown prepared statement %q", msg.PreparedStatement)}
+	}
+
+	if len(msg.Parameters) != len(stmt.paramOIDs) {
+		return statementError{fmt.Errorf("pgxloadtest: statement has %d parameters, got %d", len(stmt.paramOIDs), len(msg.Parameters))}
+	}
+
+	args := make([]interface{}, len(msg.Parameters))
+	for idx, param := range msg.Parameters {
+		arg, err := decodeValue(c.server.connInfo, stmt.paramOIDs[idx], formatCode(msg.ParameterFormatCodes, idx), param)
+		if err != nil {
+			return statementError{err}
+		}
+
+		args[idx] = arg
+	}
+
+	var resultFormats []int16
+	if stmt.handler != nil && stmt.handler.rows != nil {
+		resultFormats = make([]int16, len(stmt.handler.rows.columns))
+		for idx := range resultFormats {
+			resultFormats[idx] = formatCode(msg.ResultFormatCodes, idx)
+		}
+	}
+
+	c.portals[msg.DestinationPortal] = &portal{
+		stmt:          stmt,
+		args:          args,
+		resultFormats: resultFormats,
+	}
+
+	return c.backend.Send(&pgproto3.BindComplete{})
+}
+
+func (c *serverConn) execute(msg *pgproto3.Execute) error {
+
+	p := c.portals[msg.Portal]
+	if p == nil {
+		return statementError{fmt.Errorf("pgxloadtest: unknown portal %q", msg.Portal)}
+	}
+
+	c.server.record(p.stmt.sql, p.args)
+
+	if p.stmt.handler != nil && p.stmt.handler.err != nil {
+		return statementError{p.stmt.handler.err}
+	}
+
+	return c.sendResult(p.stmt.sql, p.stmt.handler, p.resultFormats)
+}
+
+// The format code for the value at idx, per the rules of the Bind message
+func formatCode(codes []int16, idx int) int16 {
+	switch len(codes) {
+	case 0:
+		return pgx.TextFormatCode
+	case 1:
+		return codes[0]
+	}
+
+	return codes[idx]
+}
+
+func decodeValue(ci *pgtype.ConnInfo, oid uint32, format int16, buf []byte) (interface{}, error) {
+
+	if buf == nil {
+		return nil, nil
+	}
+
+	dt, ok := ci.DataTypeForOID(oid)
+	if !ok {
+		return string(buf), nil
+	}
+
+	value := reflect.New(reflect.ValueOf(dt.Value).Elem().Type()).Interface().(pgtype.Value)
+
+	if format == pgx.BinaryFormatCode {
+		decoder, ok := value.(pgtype.BinaryDecoder)
+		if !ok {
+			return nil, errors.New("type " + dt.Name + " can't be decoded from binary")
+		}
+
+		if err := decoder.DecodeBinary(ci, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		decoder, ok := value.(pgtype.TextDecoder)
+		if !ok {
+			return string(buf), nil
+		}
+
+		if err := decoder.DecodeText(ci, buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return value.Get(), nil
+}
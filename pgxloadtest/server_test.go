@@ -0,0 +1,171 @@
+package pgxloadtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload"
+)
+
+type account struct {
+	ID   int64 `pgxload:"omitZero"`
+	Name string
+	Age  int32
+}
+
+func TestServer_Loader(t *testing.T) {
+
+	ctx := context.Background()
+
+	server, err := NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Close()
+
+	server.Handle(`INSERT INTO accounts ("name", "age") VALUES ($1, $2), ($3, $4) RETURNING "id"`).
+		WithParamTypes("text", "int4", "text", "int4").
+		WillReturnRows(NewRows("id").AddRow(int64(1)).AddRow(int64(2))).
+		WillReturnResult("INSERT 0 2")
+	server.Handle("select id, name, age from accounts where id = $1::int8").
+		WillReturnRows(NewRows("id", "name", "age").WithTypes("int8", "text", "int4").AddRow(1, "Alice", 30))
+	server.Handle("delete from accounts").
+		WillReturnError(&pgconn.PgError{Severity: "ERROR", Code: "42501", Message: "permission denied"})
+
+	conn, err := pgx.Connect(ctx, server.ConnString())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close(ctx)
+
+	loader, err := pgxload.NewPgxLoader(conn)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stmt, params, err := pgxload.NewStructInsert("accounts",
+		&account{Name: "Alice", Age: 30},
+		&account{Name: "Bob", Age: 40},
+	).WithReturningColumns("id").GenerateInsert(loader.Mapper())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var ids []int64
+	err = pgxload.RunInTransaction(ctx, loader, func(ctx context.Context, tx pgxload.PgxTxLoader) error {
+		rows, err := tx.Query(ctx, stmt, params...)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+
+		return rows.Err()
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	rows, err := loader.Query(ctx, "select id, name, age from accounts where id = $1::int8", 1)
+	if assert.NoError(t, err) {
+		var acc account
+		if assert.NoError(t, loader.Scanner(rows).ScanRow(&acc)) {
+			assert.Equal(t, account{ID: 1, Name: "Alice", Age: 30}, acc)
+		}
+	}
+
+	err = pgxload.RunInTransaction(ctx, loader, func(ctx context.Context, tx pgxload.PgxTxLoader) error {
+		_, err := tx.Exec(ctx, "delete from accounts")
+		return err
+	})
+	if pgErr, ok := err.(*pgconn.PgError); assert.True(t, ok) {
+		assert.Equal(t, "42501", pgErr.Code)
+	}
+
+	statements := server.Statements()
+	if assert.Equal(t, 7, len(statements)) {
+		assert.Equal(t, "begin", statements[0].SQL)
+		assert.Equal(t, []interface{}{"Alice", int32(30), "Bob", int32(40)}, statements[1].Args)
+		assert.Equal(t, "commit", statements[2].SQL)
+		assert.Equal(t, []interface{}{int64(1)}, statements[3].Args)
+		assert.Equal(t, "rollback", statements[6].SQL)
+	}
+}
+
+type payment struct {
+	Payer  string
+	Amount string  `pgxload:"type=numeric(10,2)"`
+	Memo   *string `pgxload:"type=jsonb"`
+}
+
+func TestServer_UnnestInsert(t *testing.T) {
+
+	ctx := context.Background()
+
+	server, err := NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Close()
+
+	server.HandleRegex(`^INSERT INTO payments`).
+		WithParamTypes("_text", "_numeric", "_text").
+		WillReturnResult("INSERT 0 2")
+
+	conn, err := pgx.Connect(ctx, server.ConnString())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close(ctx)
+
+	loader, err := pgxload.NewPgxLoader(conn)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	memo := `{"note": "a, \"b\""}`
+	stmt, params, err := pgxload.NewStructInsert("payments",
+		&payment{Payer: "Alice, \"Al\"", Amount: "10.50", Memo: &memo},
+		&payment{Payer: "Bob", Amount: "2.25"},
+	).GenerateUnnestInsert(loader.Mapper())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, `INSERT INTO payments ("payer", "amount", "memo") SELECT * FROM unnest($1::text[], $2::numeric(10,2)[], $3::jsonb[])`, stmt)
+
+	tag, err := loader.Exec(ctx, stmt, params...)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), tag.RowsAffected())
+	}
+
+	statements := server.Statements()
+	if !assert.Equal(t, 1, len(statements)) || !assert.Equal(t, 3, len(statements[0].Args)) {
+		return
+	}
+
+	// The text encoded arrays decode back to the original values, quoting and NULLs included
+	var payers []string
+	var amounts []float64
+
+	args := statements[0].Args
+	assert.NoError(t, args[0].(pgtype.Value).AssignTo(&payers))
+	assert.NoError(t, args[1].(pgtype.Value).AssignTo(&amounts))
+	memos := args[2].(*pgtype.TextArray).Elements
+
+	assert.Equal(t, []string{"Alice, \"Al\"", "Bob"}, payers)
+	assert.Equal(t, []float64{10.5, 2.25}, amounts)
+	if assert.Equal(t, 2, len(memos)) {
+		assert.Equal(t, pgtype.Text{String: memo, Status: pgtype.Present}, memos[0])
+		assert.Equal(t, pgtype.Null, memos[1].Status)
+	}
+}
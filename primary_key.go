@@ -0,0 +1,56 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// Delete the row of table matching data's pk tagged fields, returning the rows affected
+func DeleteStruct(ctx context.Context, loader QueryLoader, table string, data interface{}) (int64, error) {
+
+	if data == nil {
+		return 0, errors.New("missing data to delete")
+	}
+
+	stmt, params, err := NewStructDelete(table, data).GenerateDelete(loader.Mapper())
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := loader.Exec(ctx, stmt, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Reload dest, a pointer to a struct, from the row of table matching its pk tagged fields
+// Only the columns which would be written are selected, omit tagged fields are left as they are. Returns pgx.ErrNoRows if the row no longer exists
+func ReloadStruct(ctx context.Context, loader QueryLoader, table string, dest interface{}) error {
+
+	val := reflect.ValueOf(dest)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return errors.New("can only reload into a pointer to a struct")
+	}
+
+	extractor, err := NewStructColumnValueExtractor(loader.Mapper(), dest)
+	if err != nil {
+		return err
+	}
+
+	pkColumns, pkValues, err := extractor.ExtractPrimaryKey(dest)
+	if err != nil {
+		return err
+	}
+
+	where, params, _ := matchSyntax(pkColumns, pkValues, 0)
+
+	rows, err := loader.Query(ctx, "SELECT "+ToColumnList(extractor.WritableColumns()...)+" FROM "+table+" WHERE "+where, params...)
+	if err != nil {
+		return err
+	}
+
+	return loader.Scanner(rows).ScanRow(dest)
+}
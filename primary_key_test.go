@@ -0,0 +1,90 @@
+package pgxload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+type membership struct {
+	OrgID  int64 `pgxload:"pk"`
+	UserID int64 `pgxload:"pk"`
+	Role   string
+	Joined string `pgxload:"omit"`
+}
+
+func TestStructUpdate_GenerateUpdate(t *testing.T) {
+
+	m := DefaultConfig.generateMapper()
+
+	stmt, params, err := NewStructUpdate("memberships", &membership{OrgID: 1, UserID: 2, Role: "admin"}).
+		WithReturningColumns("role").
+		GenerateUpdate(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `UPDATE memberships SET "role" = $1 WHERE "org_id" = $2 AND "user_id" = $3 RETURNING "role"`, stmt)
+		assert.Equal(t, []interface{}{"admin", int64(1), int64(2)}, params)
+	}
+
+	exact, _, err := NewStructUpdate("memberships", &membership{OrgID: 1, UserID: 2, Role: "admin"}).GenerateExactUpdate(m, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, `UPDATE memberships SET "role" = $1 WHERE "org_id" = $2 AND "user_id" = $3`, exact)
+	}
+
+	_, _, err = NewStructUpdate("people", &upsertRow{Email: "a@b.c"}).GenerateUpdate(m)
+	assert.Error(t, err)
+}
+
+func TestDeleteAndReloadStruct(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	conn.ExpectExec(`DELETE FROM memberships WHERE "org_id" = $1 AND "user_id" = $2`).
+		WithArgs(int64(1), int64(2)).
+		WillReturnResult("DELETE 1")
+
+	affected, err := DeleteStruct(ctx, loader, "memberships", membership{OrgID: 1, UserID: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	conn.ExpectQuery(`SELECT "org_id", "user_id", "role" FROM memberships WHERE "org_id" = $1 AND "user_id" = $2`).
+		WithArgs(int64(1), int64(2)).
+		WillReturnRows(pgxloadtest.NewRows("org_id", "user_id", "role").AddRow(int64(1), int64(2), "owner"))
+
+	reloaded := &membership{OrgID: 1, UserID: 2, Joined: "2020-01-01"}
+	assert.NoError(t, ReloadStruct(ctx, loader, "memberships", reloaded))
+	assert.Equal(t, &membership{OrgID: 1, UserID: 2, Role: "owner", Joined: "2020-01-01"}, reloaded)
+
+	conn.ExpectQueryRegex(`^SELECT`).WillReturnRows(pgxloadtest.NewRows("org_id", "user_id", "role"))
+	assert.Equal(t, pgx.ErrNoRows, ReloadStruct(ctx, loader, "memberships", reloaded))
+
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+type nullablePkRow struct {
+	ID   *int64 `pgxload:"pk"`
+	Name string
+}
+
+func TestExtractPrimaryKey_Nil(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	_, _, err := NewStructUpdate("people", &nullablePkRow{Name: "a"}).GenerateUpdate(loader.Mapper())
+	assert.EqualError(t, err, "missing value for pk column id")
+
+	assert.Error(t, ReloadStruct(ctx, loader, "people", &nullablePkRow{}))
+
+	_, err = DeleteStruct(ctx, loader, "people", &nullablePkRow{})
+	assert.Error(t, err)
+
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
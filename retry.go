@@ -0,0 +1,113 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// SQLSTATE codes for serialization_failure and deadlock_detected
+const (
+	SQLStateSerializationFailure = "40001"
+	SQLStateDeadlockDetected     = "40P01"
+)
+
+// Retry policy retrying serialization failures and deadlocks up to 5 times
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	RetryableCodes: []string{SQLStateSerializationFailure, SQLStateDeadlockDetected},
+}
+
+// Determines how RunInTransaction re-runs a transaction which failed with a retryable pgconn.PgError
+// Backoff grows exponentially from InitialBackoff up to MaxBackoff (or a minute if unset), with full jitter applied
+type RetryPolicy struct {
+	// Total number of attempts, including the first. Values < 1 are treated as 1
+	MaxAttempts int
+
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// SQLSTATE codes which may be retried
+	RetryableCodes []string
+}
+
+// Determine if err wraps a pgconn.PgError with one of the retryable SQLSTATE codes
+func (p *RetryPolicy) IsRetryable(err error) bool {
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	for _, code := range p.RetryableCodes {
+		if pgErr.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Backoff never grows past this when MaxBackoff isn't set, so doubling can't overflow
+const maxUnsetBackoff = time.Minute
+
+// How long to wait before the next attempt, after the specified number of failed attempts
+func (p *RetryPolicy) backoff(failedAttempts int) time.Duration {
+
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = maxUnsetBackoff
+	}
+
+	ceiling := p.InitialBackoff
+	for i := 1; i < failedAttempts && ceiling < maxBackoff; i++ {
+		if ceiling > maxBackoff/2 {
+			ceiling = maxBackoff
+			break
+		}
+		ceiling *= 2
+	}
+
+	if ceiling > maxBackoff {
+		ceiling = maxBackoff
+	}
+
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// Run fn until it succeeds, returns a non-retryable error or attempts are exhausted
+// Returns the context error if the context is done while waiting to retry
+func (p *RetryPolicy) run(ctx context.Context, fn func() error) error {
+
+	if p == nil {
+		return fn()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !p.IsRetryable(err) {
+			return err
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
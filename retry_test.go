@@ -0,0 +1,107 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+func TestRetryPolicy_IsRetryable(t *testing.T) {
+
+	policy := DefaultRetryPolicy
+
+	assert.True(t, policy.IsRetryable(&pgconn.PgError{Code: SQLStateSerializationFailure}))
+	assert.True(t, policy.IsRetryable(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: SQLStateDeadlockDetected})))
+	assert.False(t, policy.IsRetryable(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, policy.IsRetryable(errors.New("not a pg error")))
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+
+	policy := &RetryPolicy{InitialBackoff: time.Second}
+
+	for _, failed := range []int{1, 10, 100, 1000} {
+		backoff := policy.backoff(failed)
+		assert.True(t, backoff >= 0 && backoff <= maxUnsetBackoff, "backoff %s after %d failures", backoff, failed)
+	}
+
+	policy.MaxBackoff = 2 * time.Second
+	assert.True(t, policy.backoff(100) <= 2*time.Second)
+}
+
+func TestRunInTransaction_Retry(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	cfg := &TxConfig{Retry: &RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Microsecond,
+		RetryableCodes: []string{SQLStateSerializationFailure},
+	}}
+
+	conn.ExpectBegin()
+	conn.ExpectExec("update accounts set balance = balance - 1").WillReturnError(&pgconn.PgError{Code: SQLStateSerializationFailure})
+	conn.ExpectRollback()
+	conn.ExpectBegin()
+	conn.ExpectExec("update accounts set balance = balance - 1").WillReturnResult("UPDATE 1")
+	conn.ExpectCommit()
+
+	attempts := 0
+	err := RunInTransaction(ctx, loader, func(ctx context.Context, tx PgxTxLoader) error {
+		attempts += 1
+		_, err := tx.Exec(ctx, "update accounts set balance = balance - 1")
+		return err
+	}, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestRetryPolicy_run(t *testing.T) {
+
+	policy := &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Microsecond,
+		MaxBackoff:     time.Millisecond,
+		RetryableCodes: []string{SQLStateSerializationFailure},
+	}
+
+	attempts := 0
+	err := policy.run(context.Background(), func() error {
+		attempts += 1
+		return &pgconn.PgError{Code: SQLStateSerializationFailure}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = policy.run(context.Background(), func() error {
+		attempts += 1
+		if attempts < 2 {
+			return &pgconn.PgError{Code: SQLStateSerializationFailure}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts = 0
+	err = policy.run(ctx, func() error {
+		attempts += 1
+		return &pgconn.PgError{Code: SQLStateSerializationFailure}
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, attempts)
+}
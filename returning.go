@@ -0,0 +1,135 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Execute the insert and scan each RETURNING row back into the struct it was generated from, by position
+// Every inserted struct must be a pointer, and the insert must have a returning clause.
+// Postgres doesn't guarantee RETURNING rows come back in VALUES order, this relies on it doing so for a plain multi-row insert.
+// Conflict clauses which can skip rows (DO NOTHING, a Where, or any raw WithConflict) are rejected before executing,
+// as the rows returned couldn't be matched to structs. If the row count still doesn't match (e.g. a trigger skipped a row)
+// an error is returned and no struct is modified, but the insert has already run, so use a transaction to undo it.
+// Returns the rows affected
+func ExecStructInsertReturning(ctx context.Context, loader QueryLoader, ins StructInsert) (int64, error) {
+
+	if len(ins.returning) == 0 {
+		return 0, errors.New("insert has no RETURNING clause to scan")
+	}
+
+	if len(ins.conflictStmt) > 0 || ins.conflict != nil && (ins.conflict.action == conflictDoNothing || len(ins.conflict.where) > 0) {
+		return 0, errors.New("RETURNING rows can't be matched to structs when conflicting rows may be skipped")
+	}
+
+	// Resolve duplicates first, so rows line up with the data actually inserted
+	ins, err := ins.resolveDuplicates(loader.Mapper())
+	if err != nil {
+		return 0, err
+	}
+
+	if err := returningDestinations(ins.data); err != nil {
+		return 0, err
+	}
+
+	stmt, params, err := ins.GenerateInsert(loader.Mapper())
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := loader.Query(ctx, stmt, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	return scanReturning(loader, rows, ins.data)
+}
+
+// Execute the exact update and scan its RETURNING row back into the updated struct, which must be a pointer
+// Returns the rows affected, an update WithSnapshot where nothing has changed is skipped.
+// An update matching no row is an error, as the struct couldn't be refreshed
+func ExecStructUpdateReturning(ctx context.Context, loader QueryLoader, upd StructUpdate, columnsToMatch ...string) (int64, error) {
+
+	if len(upd.returning) == 0 {
+		return 0, errors.New("update has no RETURNING clause to scan")
+	}
+
+	if err := returningDestinations([]interface{}{upd.data}); err != nil {
+		return 0, err
+	}
+
+	stmt, params, err := upd.GenerateExactUpdate(loader.Mapper(), columnsToMatch...)
+	if err == ErrNoChanges {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	rows, err := loader.Query(ctx, stmt, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	return scanReturning(loader, rows, []interface{}{upd.data})
+}
+
+// Ensure every struct to scan back into is a non-nil pointer to a struct
+func returningDestinations(data []interface{}) error {
+
+	for idx, dat := range data {
+		val := reflect.ValueOf(dat)
+		if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("row %d: can only scan RETURNING values into a pointer to a struct, got %T", idx, dat)
+		}
+	}
+
+	return nil
+}
+
+// Scan each row into the corresponding element of dest, closing rows
+// Exactly one row must be returned per element of dest. Rows are scanned into copies, so dest is only modified on success
+func scanReturning(loader CommonLoader, rows pgx.Rows, dest []interface{}) (int64, error) {
+
+	defer rows.Close()
+
+	s := &scanner{
+		rows:   rows,
+		mapper: loader.Mapper(),
+	}
+
+	scanned := make([]reflect.Value, 0, len(dest))
+	for rows.Next() {
+		if len(scanned) >= len(dest) {
+			return 0, fmt.Errorf("more RETURNING rows than the %d structs to scan them into", len(dest))
+		}
+
+		target := reflect.ValueOf(dest[len(scanned)]).Elem()
+		copied := reflect.New(target.Type())
+		copied.Elem().Set(target)
+
+		if err := s.scanStruct(copied); err != nil {
+			return 0, err
+		}
+
+		scanned = append(scanned, copied.Elem())
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(scanned) != len(dest) {
+		return 0, fmt.Errorf("got %d RETURNING rows for the %d structs to scan them into", len(scanned), len(dest))
+	}
+
+	for idx, val := range scanned {
+		reflect.ValueOf(dest[idx]).Elem().Set(val)
+	}
+
+	return rows.CommandTag().RowsAffected(), nil
+}
@@ -0,0 +1,88 @@
+package pgxload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+type returningRow struct {
+	ID      int64 `pgxload:"defaultZero"`
+	Name    string
+	Version int `pgxload:"omitZero"`
+}
+
+func TestExecStructInsertReturning(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	rows := []*returningRow{{Name: "a"}, {Name: "b"}}
+
+	conn.ExpectQuery(`INSERT INTO people ("id", "name") VALUES (DEFAULT, $1), (DEFAULT, $2) RETURNING "id", "version"`).
+		WillReturnRows(pgxloadtest.NewRows("id", "version").AddRow(int64(1), 1).AddRow(int64(2), 1))
+
+	affected, err := ExecStructInsertReturning(ctx, loader, NewStructInsert("people", rows[0], rows[1]).WithReturningColumns("id", "version"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	assert.Equal(t, []*returningRow{{ID: 1, Name: "a", Version: 1}, {ID: 2, Name: "b", Version: 1}}, rows)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	_, err = ExecStructInsertReturning(ctx, loader, NewStructInsert("people", returningRow{Name: "a"}).WithReturningColumns("id"))
+	assert.Error(t, err)
+
+	_, err = ExecStructInsertReturning(ctx, loader, NewStructInsert("people", rows[0]))
+	assert.Error(t, err)
+
+	_, err = ExecStructInsertReturning(ctx, loader, NewStructInsert("people", rows[0]).WithReturningColumns("id").OnConflictColumns("id").DoNothing())
+	assert.Error(t, err)
+
+	_, err = ExecStructInsertReturning(ctx, loader, NewStructInsert("people", rows[0]).WithReturningColumns("id").WithConflict("(id) DO NOTHING"))
+	assert.Error(t, err)
+
+	_, err = ExecStructInsertReturning(ctx, loader, NewStructInsert("people", rows[0]).WithReturningColumns("id").
+		OnConflictColumns("id").Where("people.version < EXCLUDED.version").DoUpdateAll())
+	assert.Error(t, err)
+}
+
+func TestExecStructInsertReturning_MissingRows(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	rows := []*returningRow{{Name: "a"}, {Name: "b"}}
+
+	conn.ExpectQuery(`INSERT INTO people ("id", "name") VALUES (DEFAULT, $1), (DEFAULT, $2) RETURNING "id"`).
+		WillReturnRows(pgxloadtest.NewRows("id").AddRow(int64(1)))
+
+	_, err := ExecStructInsertReturning(ctx, loader, NewStructInsert("people", rows[0], rows[1]).WithReturningColumns("id"))
+	assert.Error(t, err)
+	assert.Equal(t, []*returningRow{{Name: "a"}, {Name: "b"}}, rows)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestExecStructUpdateReturning(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	row := &returningRow{ID: 5, Name: "a", Version: 1}
+
+	conn.ExpectQuery(`UPDATE people SET "name" = $1, "version" = $2 WHERE "id" = $3 RETURNING "version"`).
+		WithArgs("a", 1, int64(5)).
+		WillReturnRows(pgxloadtest.NewRows("version").AddRow(2))
+
+	affected, err := ExecStructUpdateReturning(ctx, loader, NewStructUpdate("people", row).WithReturningColumns("version"), "id")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	assert.Equal(t, &returningRow{ID: 5, Name: "a", Version: 2}, row)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,167 @@
+package pgxload
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Returned when generating an update WithSnapshot where no column has changed
+var ErrNoChanges = errors.New("no columns changed since the snapshot")
+
+// A copy of a struct's values at a point in time, used to only update the columns which have since changed
+type Snapshot struct {
+	value reflect.Value
+}
+
+// Snapshot data, a pointer to a struct, typically right after scanning it
+// Pointers, slices and maps are copied too, so changes made through them are detected
+func Track(data interface{}) (*Snapshot, error) {
+
+	val := reflect.ValueOf(data)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("can only track a pointer to a struct")
+	}
+
+	return &Snapshot{value: deepCopy(val.Elem())}, nil
+}
+
+// Only update columns which differ from snapshot, as taken by Track
+// GenerateExactUpdate and GenerateUpdate return ErrNoChanges if nothing has changed
+func (upd StructUpdate) WithSnapshot(snapshot *Snapshot) StructUpdate {
+	return StructUpdate{
+		tableName: upd.tableName,
+		data:      upd.data,
+		returning: upd.returning,
+		snapshot:  snapshot,
+	}
+}
+
+// The extracted columns whose values differ from the snapshot
+func (s *Snapshot) changed(extractor StructColumnValueExtractor, data interface{}, extracted ExtractedColumnValues) (ExtractedColumnValues, error) {
+
+	current := reflect.Indirect(reflect.ValueOf(data))
+	if current.Type() != s.value.Type() {
+		return ExtractedColumnValues{}, fmt.Errorf("snapshot of %s can't be compared to %s", s.value.Type(), current.Type())
+	}
+
+	var changed []string
+	for _, column := range extracted.Columns() {
+		before, err := extractor.ExtractRawColumnData(s.value.Interface(), column)
+		if err != nil {
+			return ExtractedColumnValues{}, err
+		}
+
+		after, err := extractor.ExtractRawColumnData(data, column)
+		if err != nil {
+			return ExtractedColumnValues{}, err
+		}
+
+		if !reflect.DeepEqual(before, after) {
+			changed = append(changed, column)
+		}
+	}
+
+	if len(changed) == 0 {
+		return ExtractedColumnValues{}, ErrNoChanges
+	}
+
+	return extracted.withColumns(changed), nil
+}
+
+// Copy val, following pointers, slices, maps, arrays and interfaces so the copy shares no mutable state with val
+// Cyclic references are copied as cycles. Unexported struct fields can't be set, so are copied shallowly
+func deepCopy(val reflect.Value) reflect.Value {
+	c := &deepCopier{visited: make(map[deepCopyKey]reflect.Value)}
+	return c.copy(val)
+}
+
+// Identifies a pointer, slice or map already copied
+type deepCopyKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+type deepCopier struct {
+	visited map[deepCopyKey]reflect.Value
+}
+
+func (c *deepCopier) copy(val reflect.Value) reflect.Value {
+
+	switch val.Kind() {
+	case reflect.Ptr:
+		if val.IsNil() {
+			return val
+		}
+
+		key := deepCopyKey{ptr: val.Pointer(), typ: val.Type()}
+		if cpy, ok := c.visited[key]; ok {
+			return cpy
+		}
+
+		cpy := reflect.New(val.Type().Elem())
+		c.visited[key] = cpy
+		cpy.Elem().Set(c.copy(val.Elem()))
+		return cpy
+	case reflect.Slice:
+		if val.IsNil() {
+			return val
+		}
+
+		key := deepCopyKey{ptr: val.Pointer(), typ: val.Type(), len: val.Len()}
+		if cpy, ok := c.visited[key]; ok {
+			return cpy
+		}
+
+		cpy := reflect.MakeSlice(val.Type(), val.Len(), val.Len())
+		c.visited[key] = cpy
+		for idx := 0; idx < val.Len(); idx++ {
+			cpy.Index(idx).Set(c.copy(val.Index(idx)))
+		}
+		return cpy
+	case reflect.Map:
+		if val.IsNil() {
+			return val
+		}
+
+		key := deepCopyKey{ptr: val.Pointer(), typ: val.Type()}
+		if cpy, ok := c.visited[key]; ok {
+			return cpy
+		}
+
+		cpy := reflect.MakeMapWithSize(val.Type(), val.Len())
+		c.visited[key] = cpy
+		iter := val.MapRange()
+		for iter.Next() {
+			cpy.SetMapIndex(iter.Key(), c.copy(iter.Value()))
+		}
+		return cpy
+	case reflect.Interface:
+		if val.IsNil() {
+			return val
+		}
+
+		cpy := reflect.New(val.Type()).Elem()
+		cpy.Set(c.copy(val.Elem()))
+		return cpy
+	case reflect.Array:
+		cpy := reflect.New(val.Type()).Elem()
+		for idx := 0; idx < val.Len(); idx++ {
+			cpy.Index(idx).Set(c.copy(val.Index(idx)))
+		}
+		return cpy
+	case reflect.Struct:
+		cpy := reflect.New(val.Type()).Elem()
+		cpy.Set(val)
+
+		for idx := 0; idx < val.NumField(); idx++ {
+			if cpy.Field(idx).CanSet() {
+				cpy.Field(idx).Set(c.copy(val.Field(idx)))
+			}
+		}
+		return cpy
+	}
+
+	return val
+}
@@ -0,0 +1,111 @@
+package pgxload
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+type trackedRow struct {
+	ID    int64 `pgxload:"pk"`
+	Name  string
+	Email *string
+	Tags  []string
+}
+
+func TestStructUpdate_WithSnapshot(t *testing.T) {
+
+	ctx := context.Background()
+	m := DefaultConfig.generateMapper()
+
+	email := "a@b.c"
+	row := &trackedRow{ID: 1, Name: "a", Email: &email, Tags: []string{"x"}}
+
+	snapshot, err := Track(row)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, _, err = NewStructUpdate("people", row).WithSnapshot(snapshot).GenerateUpdate(m)
+	assert.Equal(t, ErrNoChanges, err)
+
+	*row.Email = "d@e.f"
+	row.Tags[0] = "y"
+
+	stmt, params, err := NewStructUpdate("people", row).WithSnapshot(snapshot).GenerateUpdate(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `UPDATE people SET "email" = $1, "tags" = $2 WHERE "id" = $3`, stmt)
+		assert.Equal(t, []interface{}{row.Email, []string{"y"}, int64(1)}, params)
+	}
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	snapshot, _ = Track(row)
+	affected, err := ExecStructUpdate(ctx, loader, NewStructUpdate("people", row).WithSnapshot(snapshot))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+
+	row.Name = "b"
+	conn.ExpectExec(`UPDATE people SET "name" = $1 WHERE "id" = $2`).WithArgs("b", int64(1)).WillReturnResult("UPDATE 1")
+
+	affected, err = ExecStructUpdate(ctx, loader, NewStructUpdate("people", row).WithSnapshot(snapshot))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	_, err = Track(*row)
+	assert.Error(t, err)
+
+	_, _, err = NewStructUpdate("people", &upsertRow{}).WithSnapshot(snapshot).GenerateExactUpdate(m, "email")
+	assert.Error(t, err)
+}
+
+type attrsRow struct {
+	ID     int64 `pgxload:"pk"`
+	Attrs  interface{}
+	Scores [2][]int
+}
+
+func TestStructUpdate_WithSnapshot_InterfaceAndArray(t *testing.T) {
+
+	m := DefaultConfig.generateMapper()
+
+	attrs := map[string]interface{}{"color": "red"}
+	row := &attrsRow{ID: 1, Attrs: attrs, Scores: [2][]int{{1}, {2}}}
+
+	snapshot, err := Track(row)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	attrs["color"] = "blue"
+	row.Scores[1][0] = 3
+
+	stmt, _, err := NewStructUpdate("people", row).WithSnapshot(snapshot).GenerateUpdate(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `UPDATE people SET "attrs" = $1, "scores" = $2 WHERE "id" = $3`, stmt)
+	}
+}
+
+type cyclicNode struct {
+	Name string
+	Next *cyclicNode
+}
+
+func TestDeepCopy_Cycle(t *testing.T) {
+
+	a := &cyclicNode{Name: "a"}
+	b := &cyclicNode{Name: "b", Next: a}
+	a.Next = b
+
+	cpy := deepCopy(reflect.ValueOf(a)).Interface().(*cyclicNode)
+
+	assert.Equal(t, "a", cpy.Name)
+	assert.Equal(t, "b", cpy.Next.Name)
+	assert.True(t, cpy.Next.Next == cpy)
+	assert.False(t, cpy == a || cpy.Next == b)
+}
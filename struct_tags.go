@@ -18,7 +18,7 @@ func parseStructTagOptsStr(optsStr string) structTagOpts {
 	opts := defaultStructTagOpts.copy()
 	if optsStr != "" {
 
-		for _, opt := range strings.Split(optsStr, ",") {
+		for _, opt := range splitStructTagOpts(optsStr) {
 			opts = opts.setOpt(opt)
 		}
 
@@ -28,11 +28,42 @@ func parseStructTagOptsStr(optsStr string) structTagOpts {
 	return defaultStructTagOpts
 }
 
+// Split tag options on commas, except those inside parentheses, so type=numeric(10,2) stays a single option
+func splitStructTagOpts(optsStr string) []string {
+
+	var opts []string
+
+	depth := 0
+	start := 0
+	for idx, r := range optsStr {
+		switch r {
+		case '(':
+			depth += 1
+		case ')':
+			if depth > 0 {
+				depth -= 1
+			}
+		case ',':
+			if depth == 0 {
+				opts = append(opts, optsStr[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+
+	return append(opts, optsStr[start:])
+}
+
 type structTagOpts struct {
 	Omit        bool
 	OmitZero    bool
 	DefaultZero bool
 	NullZero    bool
+	ShardKey    bool
+	PrimaryKey  bool
+
+	// Postgres type name used for the field's UNNEST array, e.g. type=jsonb or type=numeric(10,2)
+	PgType string
 }
 
 func (s structTagOpts) copy() structTagOpts {
@@ -41,12 +72,22 @@ func (s structTagOpts) copy() structTagOpts {
 		OmitZero:    s.OmitZero,
 		DefaultZero: s.DefaultZero,
 		NullZero:    s.NullZero,
+		ShardKey:    s.ShardKey,
+		PrimaryKey:  s.PrimaryKey,
+		PgType:      s.PgType,
 	}
 }
 
 func (s structTagOpts) setOpt(opt string) structTagOpts {
 
-	switch strings.TrimSpace(opt) {
+	opt = strings.TrimSpace(opt)
+
+	if strings.HasPrefix(opt, "type=") {
+		s.PgType = strings.TrimPrefix(opt, "type=")
+		return s
+	}
+
+	switch opt {
 	case "omit":
 		s.Omit = true
 		return s
@@ -59,6 +100,12 @@ func (s structTagOpts) setOpt(opt string) structTagOpts {
 	case "nullZero":
 		s.NullZero = true
 		return s
+	case "shardKey":
+		s.ShardKey = true
+		return s
+	case "pk":
+		s.PrimaryKey = true
+		return s
 	}
 
 	return s
@@ -0,0 +1,100 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Configuration options to pass to RunInTransaction
+type TxConfig struct {
+	// Optional policy used to re-run the transaction on retryable errors
+	// The transaction func must be safe to call multiple times when set
+	Retry *RetryPolicy
+
+	// Optional role to SET LOCAL ROLE to at the start of the transaction
+	Role string
+
+	// Optional settings to SET LOCAL at the start of the transaction, e.g. app.tenant_id for row level security
+	// As they're local, they're reset when the transaction ends and can't leak across pooled connections
+	LocalSettings map[string]string
+}
+
+func resolveTxConfig(config []*TxConfig) (*TxConfig, error) {
+
+	if len(config) > 1 {
+		return nil, errors.New("specify only 1 config option")
+	}
+
+	if len(config) == 1 && config[0] != nil {
+		return config[0], nil
+	}
+
+	return &TxConfig{}, nil
+}
+
+var settingNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)*$`)
+
+// Statements to run at the start of the transaction, for the role and local settings
+func (c *TxConfig) localStatements() ([]string, error) {
+
+	var stmts []string
+
+	if len(c.Role) > 0 {
+		stmts = append(stmts, "SET LOCAL ROLE "+QuoteIdentifier(c.Role))
+	}
+
+	names := make([]string, 0, len(c.LocalSettings))
+	for name := range c.LocalSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !settingNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid setting name %q", name)
+		}
+
+		stmts = append(stmts, fmt.Sprintf("SET LOCAL %s = %s", name, QuoteLiteral(c.LocalSettings[name])))
+	}
+
+	return stmts, nil
+}
+
+// Apply the role and local settings to a newly started transaction
+func (c *TxConfig) applyLocal(ctx context.Context, tx PgxTxLoader) error {
+
+	stmts, err := c.localStatements()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Quote an identifier (e.g. a role name) for direct inclusion in SQL
+func QuoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// Quote a string literal for direct inclusion in SQL
+// Backslashes are escaped using the E'...' syntax, so the result is safe regardless of standard_conforming_strings
+func QuoteLiteral(literal string) string {
+
+	literal = strings.Replace(literal, `'`, `''`, -1)
+
+	if strings.Contains(literal, `\`) {
+		return `E'` + strings.Replace(literal, `\`, `\\`, -1) + `'`
+	}
+
+	return `'` + literal + `'`
+}
@@ -0,0 +1,84 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+func TestQuoting(t *testing.T) {
+
+	assert.Equal(t, `"app_user"`, QuoteIdentifier("app_user"))
+	assert.Equal(t, `"we""ird"`, QuoteIdentifier(`we"ird`))
+	assert.Equal(t, `'tenant'`, QuoteLiteral("tenant"))
+	assert.Equal(t, `'it''s'`, QuoteLiteral("it's"))
+	assert.Equal(t, `E'a\\b''c'`, QuoteLiteral(`a\b'c`))
+}
+
+func TestTxConfig_localStatements(t *testing.T) {
+
+	cfg := &TxConfig{
+		Role: "tenant_role",
+		LocalSettings: map[string]string{
+			"app.user_id":   "7",
+			"app.tenant_id": "1'; DROP TABLE users; --",
+		},
+	}
+
+	stmts, err := cfg.localStatements()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			`SET LOCAL ROLE "tenant_role"`,
+			`SET LOCAL app.tenant_id = '1''; DROP TABLE users; --'`,
+			`SET LOCAL app.user_id = '7'`,
+		}, stmts)
+	}
+
+	cfg = &TxConfig{
+		LocalSettings: map[string]string{
+			"app.tenant_id = 1; DROP TABLE users; --": "1",
+		},
+	}
+
+	_, err = cfg.localStatements()
+	assert.Error(t, err)
+}
+
+func TestRunInTransaction_LocalSettings(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	cfg := &TxConfig{Role: "app_user", LocalSettings: map[string]string{"app.tenant_id": "42"}}
+
+	conn.ExpectBegin()
+	conn.ExpectExec(`SET LOCAL ROLE "app_user"`)
+	conn.ExpectExec(`SET LOCAL app.tenant_id = '42'`)
+	conn.ExpectExec("select 1")
+	conn.ExpectCommit()
+
+	err := RunInTransaction(ctx, loader, func(ctx context.Context, tx PgxTxLoader) error {
+		_, err := tx.Exec(ctx, "select 1")
+		return err
+	}, cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	conn.ExpectBegin()
+	conn.ExpectExec(`SET LOCAL ROLE "app_user"`).WillReturnError(errors.New("permission denied"))
+	conn.ExpectRollback()
+
+	called := false
+	err = RunInTransaction(ctx, loader, func(ctx context.Context, tx PgxTxLoader) error {
+		called = true
+		return nil
+	}, cfg)
+	assert.EqualError(t, err, "permission denied")
+	assert.False(t, called)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -1,6 +1,7 @@
 package pgxload
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -14,6 +15,7 @@ func NewStructUpdate(tableName string, data interface{}) StructUpdate {
 		tableName: tableName,
 		data:      data,
 		returning: "",
+		snapshot:  nil,
 	}
 }
 
@@ -21,6 +23,7 @@ type StructUpdate struct {
 	tableName string
 	data      interface{}
 	returning string
+	snapshot  *Snapshot
 }
 
 func (upd StructUpdate) WithReturning(str string) StructUpdate {
@@ -28,6 +31,7 @@ func (upd StructUpdate) WithReturning(str string) StructUpdate {
 		tableName: upd.tableName,
 		data:      upd.data,
 		returning: str,
+		snapshot:  upd.snapshot,
 	}
 }
 
@@ -95,41 +99,108 @@ func (upd StructUpdate) GenerateGenericUpdate(m *reflectx.Mapper, afterUpd strin
 	return stmt + " " + afterUpd, params, nil
 }
 
-func (upd StructUpdate) GenerateExactUpdate(m *reflectx.Mapper, columnToMatch string) (string, []interface{}, error) {
+// Generate an update matching the row by columnsToMatch, which are not updated
+// Composite keys can be matched by passing multiple columns. Without any columns the pk tagged fields are matched
+func (upd StructUpdate) GenerateExactUpdate(m *reflectx.Mapper, columnsToMatch ...string) (string, []interface{}, error) {
 
-	columnToMatch = strings.TrimSpace(columnToMatch)
+	var matchColumns []string
+	for _, column := range columnsToMatch {
+		if column = strings.TrimSpace(column); len(column) > 0 {
+			matchColumns = append(matchColumns, column)
+		}
+	}
 
-	if len(columnToMatch) <= 0 {
-		return "", nil, errors.New("missing column to match")
+	// Without a column to match, match on the pk tagged fields
+	if len(matchColumns) == 0 {
+		return upd.GenerateUpdate(m)
 	}
 
-	stmt := "UPDATE " + upd.tableName + " SET "
+	if upd.data == nil {
+		return "", nil, errors.New("missing data to update")
+	}
 
 	extractor, err := NewStructColumnValueExtractor(m, upd.data)
 	if err != nil {
 		return "", nil, err
 	}
 
-	extractor = extractor.WithOmitColumns(columnToMatch)
+	matchValues := make([]interface{}, len(matchColumns))
+	for idx, column := range matchColumns {
+		matchValues[idx], err = extractor.ExtractRawColumnData(upd.data, column)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if isNilValue(matchValues[idx]) {
+			return "", nil, errors.New("missing value for column to match " + column)
+		}
+	}
+
+	return upd.generateMatchedUpdate(extractor, matchColumns, matchValues)
+}
+
+// Execute an exact update (see GenerateExactUpdate), returning the rows affected
+// An update WithSnapshot where nothing has changed is skipped, returning 0 rows affected
+func ExecStructUpdate(ctx context.Context, loader QueryLoader, upd StructUpdate, columnsToMatch ...string) (int64, error) {
+
+	stmt, params, err := upd.GenerateExactUpdate(loader.Mapper(), columnsToMatch...)
+	if err == ErrNoChanges {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
 
-	toMatchVal, err := extractor.ExtractRawColumnData(upd.data, columnToMatch)
+	tag, err := loader.Exec(ctx, stmt, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Generate an update matching the row by the struct's pk tagged fields, which are not updated
+func (upd StructUpdate) GenerateUpdate(m *reflectx.Mapper) (string, []interface{}, error) {
+
+	if upd.data == nil {
+		return "", nil, errors.New("missing data to update")
+	}
+
+	extractor, err := NewStructColumnValueExtractor(m, upd.data)
 	if err != nil {
 		return "", nil, err
 	}
 
-	if toMatchVal == nil {
-		return "", nil, errors.New("failed to locate column to match")
+	pkColumns, pkValues, err := extractor.ExtractPrimaryKey(upd.data)
+	if err != nil {
+		return "", nil, err
 	}
 
-	extracted, err := extractor.Extract(upd.data)
+	return upd.generateMatchedUpdate(extractor, pkColumns, pkValues)
+}
+
+func (upd StructUpdate) generateMatchedUpdate(extractor StructColumnValueExtractor, matchColumns []string, matchValues []interface{}) (string, []interface{}, error) {
+
+	extracted, err := extractor.WithOmitColumns(matchColumns...).Extract(upd.data)
 	if err != nil {
 		return "", nil, err
 	}
 
+	if upd.snapshot != nil {
+		extracted, err = upd.snapshot.changed(extractor, upd.data, extracted)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if len(extracted.Columns()) == 0 {
+		return "", nil, errors.New("no columns to update")
+	}
+
 	updStmt, params, offset := extracted.UpdateSyntax(0)
-	params = append(params, toMatchVal)
+	where, whereParams, _ := matchSyntax(matchColumns, matchValues, offset)
 
-	stmt += fmt.Sprintf("%s WHERE %s = $%d", updStmt, columnToMatch, offset+1)
+	stmt := "UPDATE " + upd.tableName + " SET " + updStmt + " WHERE " + where
+	params = append(params, whereParams...)
 
 	if len(upd.returning) > 0 {
 		stmt += " RETURNING " + upd.returning
@@ -137,3 +208,25 @@ func (upd StructUpdate) GenerateExactUpdate(m *reflectx.Mapper, columnToMatch st
 
 	return stmt, params, nil
 }
+
+// Whether val is nil, or a nil pointer
+func isNilValue(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(val)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// "a" = $1 AND "b" = $2 matching columns to values, with parameters numbered after paramOffset
+func matchSyntax(columns []string, values []interface{}, paramOffset int) (string, []interface{}, int) {
+
+	conditions := make([]string, len(columns))
+	for idx, column := range columns {
+		paramOffset += 1
+		conditions[idx] = fmt.Sprintf("%s = $%d", QuotedColumn(column), paramOffset)
+	}
+
+	return strings.Join(conditions, " AND "), values, paramOffset
+}
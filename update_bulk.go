@@ -0,0 +1,309 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// Alias of the VALUES list in bulk updates
+const bulkUpdateValuesAlias = "v"
+
+func NewStructBulkUpdate(tableName string, data ...interface{}) StructBulkUpdate {
+	return StructBulkUpdate{
+		tableName:    tableName,
+		data:         data,
+		matchColumns: nil,
+		returning:    "",
+	}
+}
+
+// Updates many structs in a single UPDATE ... FROM (VALUES ...) statement
+type StructBulkUpdate struct {
+	tableName    string
+	data         []interface{}
+	matchColumns []string
+	returning    string
+}
+
+// Match rows by cols instead of the pk tagged fields
+func (upd StructBulkUpdate) WithMatchColumns(cols ...string) StructBulkUpdate {
+	return StructBulkUpdate{
+		tableName:    upd.tableName,
+		data:         upd.data,
+		matchColumns: cols,
+		returning:    upd.returning,
+	}
+}
+
+// Columns in str should be qualified with the table name, as the VALUES list has columns of the same names
+func (upd StructBulkUpdate) WithReturning(str string) StructBulkUpdate {
+	return StructBulkUpdate{
+		tableName:    upd.tableName,
+		data:         upd.data,
+		matchColumns: upd.matchColumns,
+		returning:    str,
+	}
+}
+
+// Return cols of the updated table
+func (upd StructBulkUpdate) WithReturningColumns(cols ...string) StructBulkUpdate {
+
+	qualified := make([]string, len(cols))
+	for idx, col := range cols {
+		qualified[idx] = upd.tableName + "." + QuotedColumn(strings.TrimSpace(col))
+	}
+
+	return upd.WithReturning(strings.Join(qualified, ", "))
+}
+
+// Generate UPDATE t SET ... FROM (VALUES ...) AS v(...) WHERE t.key = v.key, matching by the pk tagged fields or WithMatchColumns
+// Every value is cast to a type derived from its Go field type, so columns whose type differs from it
+// (e.g. a string field for a uuid, enum or date column) must set the type tag option (e.g. pgxload:"type=uuid").
+// Each row has its own values, so every struct must produce the same columns and none may be DEFAULT.
+// Structs sharing the same values to match are an error, as Postgres would update the row from an arbitrary one of them
+func (upd StructBulkUpdate) GenerateBulkUpdate(m *reflectx.Mapper) (string, []interface{}, error) {
+
+	plan, err := upd.plan(m)
+	if err != nil {
+		return "", nil, err
+	}
+
+	stmt, params := plan.statement(upd, plan.rows)
+	if len(params) > MaxBindParameters {
+		return "", nil, fmt.Errorf("bulk update needs %d parameters, more than the limit of %d, use ExecStructBulkUpdate to split it", len(params), MaxBindParameters)
+	}
+
+	return stmt, params, nil
+}
+
+// Execute the bulk update in statements of at most maxParams bind parameters, all within a single transaction
+// If loader is already a transaction the statements run in a savepoint. maxParams <= 0 uses MaxBindParameters.
+// Returns the total rows affected across all statements
+func ExecStructBulkUpdate(ctx context.Context, loader QueryLoader, upd StructBulkUpdate, maxParams int) (int64, error) {
+
+	if maxParams <= 0 {
+		maxParams = MaxBindParameters
+	}
+
+	plan, err := upd.plan(loader.Mapper())
+	if err != nil {
+		return 0, err
+	}
+
+	var batches [][][]interface{}
+	var chunk [][]interface{}
+	var chunkParams int
+
+	for idx, values := range plan.rows {
+		rowParams := 0
+		for _, val := range values {
+			if val != nil {
+				rowParams += 1
+			}
+		}
+
+		if rowParams > maxParams {
+			return 0, fmt.Errorf("row %d needs %d parameters, more than the limit of %d", idx, rowParams, maxParams)
+		}
+
+		if chunkParams+rowParams > maxParams {
+			batches = append(batches, chunk)
+			chunk, chunkParams = nil, 0
+		}
+
+		chunk = append(chunk, values)
+		chunkParams += rowParams
+	}
+
+	batches = append(batches, chunk)
+
+	var affected int64
+	err = RunInNestedTransaction(ctx, loader, func(ctx context.Context, tx PgxTxLoader) error {
+		affected = 0
+
+		for _, rows := range batches {
+			stmt, params := plan.statement(upd, rows)
+
+			tag, err := tx.Exec(ctx, stmt, params...)
+			if err != nil {
+				return err
+			}
+
+			affected += tag.RowsAffected()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return affected, nil
+}
+
+// The columns, types and per row values of a bulk update
+type bulkUpdatePlan struct {
+	setColumns   []string
+	matchColumns []string
+	types        []string
+
+	// Values of the set then match columns for each struct, nil for NULL
+	rows [][]interface{}
+}
+
+// Extract and validate every struct's values
+func (upd StructBulkUpdate) plan(m *reflectx.Mapper) (bulkUpdatePlan, error) {
+
+	var plan bulkUpdatePlan
+
+	if len(upd.data) == 0 {
+		return plan, errors.New("missing data to update")
+	}
+
+	extractor, err := NewStructColumnValueExtractor(m, upd.data[0])
+	if err != nil {
+		return plan, err
+	}
+
+	plan.matchColumns = upd.matchColumns
+	if len(plan.matchColumns) == 0 {
+		plan.matchColumns = extractor.PrimaryKeyColumns()
+	}
+
+	if len(plan.matchColumns) == 0 {
+		return plan, errors.New("missing columns to match, tag fields with pk or use WithMatchColumns")
+	}
+
+	setExtractor := extractor.WithOmitColumns(plan.matchColumns...)
+
+	keys := make(map[string][]int)
+	var keyOrder []string
+
+	for idx, dat := range upd.data {
+		extracted, err := setExtractor.Extract(dat)
+		if err != nil {
+			return plan, err
+		}
+
+		if idx == 0 {
+			plan.setColumns = extracted.Columns()
+			if len(plan.setColumns) == 0 {
+				return plan, errors.New("no columns to update")
+			}
+
+			for _, column := range append(append([]string(nil), plan.setColumns...), plan.matchColumns...) {
+				typeName, err := extractor.columnPgType(column)
+				if err != nil {
+					return plan, err
+				}
+
+				plan.types = append(plan.types, typeName)
+			}
+		} else if strings.Join(extracted.Columns(), ",") != strings.Join(plan.setColumns, ",") {
+			return plan, fmt.Errorf("row %d: columns %v differ from the first row's columns %v", idx, extracted.Columns(), plan.setColumns)
+		}
+
+		values := make([]interface{}, 0, len(plan.types))
+		for _, column := range plan.setColumns {
+			val, _ := extracted.ColumnValue(column)
+			if val.UseDefault() {
+				return plan, fmt.Errorf("row %d: column %s is DEFAULT, which bulk updates can't express", idx, column)
+			}
+
+			values = append(values, val.Value())
+		}
+
+		matchValues := make([]interface{}, 0, len(plan.matchColumns))
+		for _, column := range plan.matchColumns {
+			val, err := extractor.ExtractRawColumnData(dat, column)
+			if err != nil {
+				return plan, err
+			}
+
+			if isNilValue(val) {
+				return plan, fmt.Errorf("row %d: missing value for column to match %s", idx, column)
+			}
+
+			matchValues = append(matchValues, val)
+		}
+
+		key, err := matchKey(matchValues)
+		if err != nil {
+			return plan, fmt.Errorf("row %d: %w", idx, err)
+		}
+
+		if _, ok := keys[key]; !ok {
+			keyOrder = append(keyOrder, key)
+		}
+		keys[key] = append(keys[key], idx)
+
+		plan.rows = append(plan.rows, append(values, matchValues...))
+	}
+
+	var duplicates []string
+	for _, key := range keyOrder {
+		if rows := keys[key]; len(rows) > 1 {
+			rowNums := make([]string, len(rows))
+			for i, row := range rows {
+				rowNums[i] = strconv.Itoa(row)
+			}
+
+			duplicates = append(duplicates, "rows "+strings.Join(rowNums, ", "))
+		}
+	}
+
+	if len(duplicates) > 0 {
+		return plan, fmt.Errorf("duplicate match keys (%s): %s", strings.Join(plan.matchColumns, ", "), strings.Join(duplicates, "; "))
+	}
+
+	return plan, nil
+}
+
+// The statement updating rows, each holding the set then match column values of one struct
+func (plan bulkUpdatePlan) statement(upd StructBulkUpdate, rows [][]interface{}) (string, []interface{}) {
+
+	var params []interface{}
+
+	tuples := make([]string, len(rows))
+	for idx, values := range rows {
+		placeholders := make([]string, len(values))
+		for colIdx, val := range values {
+			if val == nil {
+				placeholders[colIdx] = "NULL::" + plan.types[colIdx]
+				continue
+			}
+
+			params = append(params, val)
+			placeholders[colIdx] = fmt.Sprintf("$%d::%s", len(params), plan.types[colIdx])
+		}
+
+		tuples[idx] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	sets := make([]string, len(plan.setColumns))
+	for idx, column := range plan.setColumns {
+		sets[idx] = QuotedColumn(column) + " = " + bulkUpdateValuesAlias + "." + QuotedColumn(column)
+	}
+
+	conditions := make([]string, len(plan.matchColumns))
+	for idx, column := range plan.matchColumns {
+		conditions[idx] = upd.tableName + "." + QuotedColumn(column) + " = " + bulkUpdateValuesAlias + "." + QuotedColumn(column)
+	}
+
+	valueColumns := append(append([]string(nil), plan.setColumns...), plan.matchColumns...)
+
+	stmt := "UPDATE " + upd.tableName + " SET " + strings.Join(sets, ", ") +
+		" FROM (VALUES " + strings.Join(tuples, ", ") + ") AS " + bulkUpdateValuesAlias + "(" + ToColumnList(valueColumns...) + ")" +
+		" WHERE " + strings.Join(conditions, " AND ")
+
+	if len(upd.returning) > 0 {
+		stmt += " RETURNING " + upd.returning
+	}
+
+	return stmt, params
+}
@@ -0,0 +1,134 @@
+package pgxload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+type bulkRow struct {
+	ID       int64 `pgxload:"pk"`
+	Name     string
+	Nickname string `pgxload:"nullZero"`
+	Data     string `pgxload:"type=jsonb"`
+}
+
+type bulkUntaggedRow struct {
+	Email string
+	Name  string
+	Age   int `pgxload:"omitZero"`
+}
+
+type bulkDefaultRow struct {
+	ID      int64 `pgxload:"pk"`
+	Version int   `pgxload:"defaultZero"`
+}
+
+type bulkUUIDRow struct {
+	ID   string `pgxload:"pk,type=uuid"`
+	Name string
+}
+
+func TestStructBulkUpdate_GenerateBulkUpdate(t *testing.T) {
+
+	m := DefaultConfig.generateMapper()
+
+	stmt, params, err := NewStructBulkUpdate("people",
+		&bulkRow{ID: 1, Name: "a", Nickname: "ay", Data: "{}"},
+		&bulkRow{ID: 2, Name: "b", Data: "[]"},
+	).WithReturningColumns("id").GenerateBulkUpdate(m)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, `UPDATE people SET "name" = v."name", "nickname" = v."nickname", "data" = v."data"`+
+			` FROM (VALUES ($1::text, $2::text, $3::jsonb, $4::int8), ($5::text, NULL::text, $6::jsonb, $7::int8)) AS v("name", "nickname", "data", "id")`+
+			` WHERE people."id" = v."id" RETURNING people."id"`, stmt)
+		assert.Equal(t, []interface{}{"a", "ay", "{}", int64(1), "b", "[]", int64(2)}, params)
+	}
+
+	stmt, _, err = NewStructBulkUpdate("people", &bulkRow{ID: 1, Name: "a"}).WithMatchColumns("id", "name").GenerateBulkUpdate(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `UPDATE people SET "nickname" = v."nickname", "data" = v."data"`+
+			` FROM (VALUES (NULL::text, $1::jsonb, $2::int8, $3::text)) AS v("nickname", "data", "id", "name")`+
+			` WHERE people."id" = v."id" AND people."name" = v."name"`, stmt)
+	}
+
+	stmt, _, err = NewStructBulkUpdate("people", &bulkUUIDRow{ID: "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", Name: "a"}).GenerateBulkUpdate(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `UPDATE people SET "name" = v."name" FROM (VALUES ($1::text, $2::uuid)) AS v("name", "id") WHERE people."id" = v."id"`, stmt)
+	}
+}
+
+func TestStructBulkUpdate_GenerateBulkUpdateErrors(t *testing.T) {
+
+	m := DefaultConfig.generateMapper()
+
+	_, _, err := NewStructBulkUpdate("people", &bulkUntaggedRow{Email: "a"}).GenerateBulkUpdate(m)
+	assert.EqualError(t, err, "missing columns to match, tag fields with pk or use WithMatchColumns")
+
+	_, _, err = NewStructBulkUpdate("people", &bulkUntaggedRow{Email: "a"}, &bulkUntaggedRow{Email: "b", Age: 2}).WithMatchColumns("email").GenerateBulkUpdate(m)
+	assert.EqualError(t, err, "row 1: columns [name age] differ from the first row's columns [name]")
+
+	_, _, err = NewStructBulkUpdate("people", &bulkDefaultRow{ID: 1}).GenerateBulkUpdate(m)
+	assert.EqualError(t, err, "row 0: column version is DEFAULT, which bulk updates can't express")
+
+	_, _, err = NewStructBulkUpdate("people",
+		&bulkRow{ID: 1, Name: "a"},
+		&bulkRow{ID: 2, Name: "b"},
+		&bulkRow{ID: 1, Name: "c"},
+	).GenerateBulkUpdate(m)
+	assert.EqualError(t, err, "duplicate match keys (id): rows 0, 2")
+
+	data := make([]interface{}, MaxBindParameters/4+1)
+	for idx := range data {
+		data[idx] = &bulkRow{ID: int64(idx), Name: "a", Nickname: "b", Data: "{}"}
+	}
+
+	_, _, err = NewStructBulkUpdate("people", data...).GenerateBulkUpdate(m)
+	assert.EqualError(t, err, "bulk update needs 65536 parameters, more than the limit of 65535, use ExecStructBulkUpdate to split it")
+}
+
+func TestExecStructBulkUpdate(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	upd := NewStructBulkUpdate("people",
+		&bulkRow{ID: 1, Name: "a", Data: "{}"},
+		&bulkRow{ID: 2, Name: "b", Data: "{}"},
+		&bulkRow{ID: 3, Name: "c", Data: "{}"},
+	)
+
+	conn.ExpectBegin()
+	conn.ExpectExec(`UPDATE people SET "name" = v."name", "nickname" = v."nickname", "data" = v."data"`+
+		` FROM (VALUES ($1::text, NULL::text, $2::jsonb, $3::int8), ($4::text, NULL::text, $5::jsonb, $6::int8)) AS v("name", "nickname", "data", "id")`+
+		` WHERE people."id" = v."id"`).
+		WithArgs("a", "{}", int64(1), "b", "{}", int64(2)).
+		WillReturnResult("UPDATE 2")
+	conn.ExpectExec(`UPDATE people SET "name" = v."name", "nickname" = v."nickname", "data" = v."data"`+
+		` FROM (VALUES ($1::text, NULL::text, $2::jsonb, $3::int8)) AS v("name", "nickname", "data", "id")`+
+		` WHERE people."id" = v."id"`).
+		WithArgs("c", "{}", int64(3)).
+		WillReturnResult("UPDATE 1")
+	conn.ExpectCommit()
+
+	affected, err := ExecStructBulkUpdate(ctx, loader, upd, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	conn.ExpectBegin()
+	conn.ExpectExecRegex(`^UPDATE people`).WillReturnResult("UPDATE 2")
+	conn.ExpectExecRegex(`^UPDATE people`).WillReturnError(assert.AnError)
+	conn.ExpectRollback()
+
+	_, err = ExecStructBulkUpdate(ctx, loader, upd, 6)
+	assert.Equal(t, assert.AnError, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	_, err = ExecStructBulkUpdate(ctx, loader, upd, 2)
+	assert.EqualError(t, err, "row 0 needs 3 parameters, more than the limit of 2")
+}
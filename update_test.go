@@ -0,0 +1,31 @@
+package pgxload
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantRow struct {
+	TenantID int64
+	ID       *int64
+	Name     string
+}
+
+func TestStructUpdate_GenerateExactUpdate(t *testing.T) {
+
+	m := DefaultConfig.generateMapper()
+
+	id := int64(7)
+	stmt, params, err := NewStructUpdate("things", &tenantRow{TenantID: 3, ID: &id, Name: "a"}).GenerateExactUpdate(m, "tenant_id", "id")
+	if assert.NoError(t, err) {
+		assert.Equal(t, `UPDATE things SET "name" = $1 WHERE "tenant_id" = $2 AND "id" = $3`, stmt)
+		assert.Equal(t, []interface{}{"a", int64(3), &id}, params)
+	}
+
+	_, _, err = NewStructUpdate("things", &tenantRow{TenantID: 3, Name: "a"}).GenerateExactUpdate(m, "tenant_id", "id")
+	assert.EqualError(t, err, "missing value for column to match id")
+
+	_, _, err = NewStructUpdate("things", &tenantRow{TenantID: 3, ID: &id}).GenerateExactUpdate(m, "tenant_id", "missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,414 @@
+package pgxload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+type conflictAction int
+
+const (
+	conflictDoNothing conflictAction = iota
+	conflictDoUpdateAll
+	conflictDoUpdateColumns
+)
+
+// A structured ON CONFLICT clause, generated from the inserted columns
+type conflictClause struct {
+	columns    []string
+	constraint string
+
+	action        conflictAction
+	updateColumns []string
+	where         string
+}
+
+// Conflict target of an upsert, finish it with DoNothing, DoUpdateAll or DoUpdateColumns
+type OnConflict struct {
+	ins    StructInsert
+	clause conflictClause
+}
+
+// Upsert on a conflict over columns, which must match a unique index
+func (ins StructInsert) OnConflictColumns(cols ...string) OnConflict {
+	return OnConflict{
+		ins:    ins,
+		clause: conflictClause{columns: cols},
+	}
+}
+
+// Upsert on a conflict with the named constraint
+func (ins StructInsert) OnConflictConstraint(name string) OnConflict {
+	return OnConflict{
+		ins:    ins,
+		clause: conflictClause{constraint: name},
+	}
+}
+
+// Only update conflicting rows matching cond, e.g. people.version < EXCLUDED.version
+// Combining it with DoNothing is an error
+func (c OnConflict) Where(cond string) OnConflict {
+	c.clause.where = strings.TrimSpace(cond)
+	return c
+}
+
+// Skip rows which conflict
+func (c OnConflict) DoNothing() StructInsert {
+	c.clause.action = conflictDoNothing
+	return c.finish()
+}
+
+// Update every inserted column except the conflict columns from EXCLUDED
+// Columns which are DEFAULT for every row are left alone, so e.g. a defaultZero id isn't replaced
+func (c OnConflict) DoUpdateAll() StructInsert {
+	c.clause.action = conflictDoUpdateAll
+	return c.finish()
+}
+
+// Update only cols from EXCLUDED
+func (c OnConflict) DoUpdateColumns(cols ...string) StructInsert {
+	c.clause.action = conflictDoUpdateColumns
+	c.clause.updateColumns = cols
+	return c.finish()
+}
+
+func (c OnConflict) finish() StructInsert {
+	clause := c.clause
+
+	return StructInsert{
+		tableName:    c.ins.tableName,
+		data:         c.ins.data,
+		returning:    c.ins.returning,
+		conflictStmt: "",
+		conflict:     &clause,
+		insertedFlag: c.ins.insertedFlag,
+		duplicates:   c.ins.duplicates,
+	}
+}
+
+// Columns which at least one row inserts a value (not DEFAULT) into
+func insertedColumns(columns []string, rows []ExtractedColumnValues) []string {
+
+	var inserted []string
+	for _, column := range columns {
+		for _, row := range rows {
+			if val, ok := row.ColumnValue(column); ok && !val.UseDefault() {
+				inserted = append(inserted, column)
+				break
+			}
+		}
+	}
+
+	return inserted
+}
+
+// The ON CONFLICT clause of the insert, empty if there is none
+// columns are the inserted columns DoUpdateAll updates
+func (ins StructInsert) conflictSyntax(columns []string) (string, error) {
+
+	if ins.conflict == nil {
+		if len(ins.conflictStmt) > 0 {
+			return " ON CONFLICT " + ins.conflictStmt, nil
+		}
+
+		return "", nil
+	}
+
+	c := ins.conflict
+
+	stmt := " ON CONFLICT "
+	if len(c.constraint) > 0 {
+		stmt += "ON CONSTRAINT " + QuotedColumn(c.constraint)
+	} else if len(c.columns) > 0 {
+		stmt += "(" + ToColumnList(c.columns...) + ")"
+	} else if c.action != conflictDoNothing {
+		return "", errors.New("ON CONFLICT DO UPDATE requires conflict columns or a constraint")
+	}
+
+	if c.action == conflictDoNothing {
+		if len(c.where) > 0 {
+			return "", errors.New("ON CONFLICT DO NOTHING can't have a Where, it only applies to DO UPDATE")
+		}
+
+		return strings.TrimSuffix(stmt, " ") + " DO NOTHING", nil
+	}
+
+	updateColumns := c.updateColumns
+	if c.action == conflictDoUpdateAll {
+		keys := make(map[string]bool, len(c.columns))
+		for _, column := range c.columns {
+			keys[column] = true
+		}
+
+		updateColumns = nil
+		for _, column := range columns {
+			if !keys[column] {
+				updateColumns = append(updateColumns, column)
+			}
+		}
+	}
+
+	if len(updateColumns) == 0 {
+		return "", errors.New("no columns to update on conflict")
+	}
+
+	sets := make([]string, len(updateColumns))
+	for idx, column := range updateColumns {
+		sets[idx] = QuotedColumn(column) + " = EXCLUDED." + QuotedColumn(column)
+	}
+
+	stmt += " DO UPDATE SET " + strings.Join(sets, ", ")
+
+	if len(c.where) > 0 {
+		stmt += " WHERE " + c.where
+	}
+
+	return stmt, nil
+}
+
+// Name of the column WithInsertedFlag adds to RETURNING
+const InsertedFlagColumn = "inserted"
+
+// Append (xmax = 0) AS inserted to RETURNING, which is true for rows the upsert inserted and false for rows it updated
+func (ins StructInsert) WithInsertedFlag() StructInsert {
+	return StructInsert{
+		tableName:    ins.tableName,
+		data:         ins.data,
+		returning:    ins.returning,
+		conflictStmt: ins.conflictStmt,
+		conflict:     ins.conflict,
+		insertedFlag: true,
+		duplicates:   ins.duplicates,
+	}
+}
+
+// The RETURNING clause of the insert, empty if there is none
+func (ins StructInsert) returningSyntax() string {
+
+	returning := ins.returning
+	if ins.insertedFlag {
+		if len(returning) > 0 {
+			returning += ", "
+		}
+
+		returning += "(xmax = 0) AS " + QuotedColumn(InsertedFlagColumn)
+	}
+
+	if len(returning) == 0 {
+		return ""
+	}
+
+	return " RETURNING " + returning
+}
+
+// Execute an upsert generated WithInsertedFlag, returning whether each returned row was inserted (true) or updated (false)
+// If dest is non-nil the other RETURNING columns of each row are scanned into it, so it should be a pointer to a slice of structs.
+// Rows skipped by DO NOTHING aren't returned, so flags and dest only cover inserted and updated rows
+func ExecStructUpsert(ctx context.Context, loader QueryLoader, ins StructInsert, dest interface{}) ([]bool, error) {
+
+	if !ins.insertedFlag {
+		ins = ins.WithInsertedFlag()
+	}
+
+	var val reflect.Value
+	if dest != nil {
+		var err error
+		if val, err = prepareInput(dest); err != nil {
+			return nil, err
+		}
+
+		if val.Kind() != reflect.Slice {
+			return nil, errors.New("upsert destination must be a pointer to a slice")
+		}
+	}
+
+	stmt, params, err := ins.GenerateInsert(loader.Mapper())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := loader.Query(ctx, stmt, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := ColumnNames(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	flagIdx := len(columns) - 1
+	if flagIdx < 0 || columns[flagIdx] != InsertedFlagColumn {
+		return nil, errors.New("upsert did not return the " + InsertedFlagColumn + " flag")
+	}
+
+	var traversals [][]int
+	if dest != nil {
+		traversals = loader.Mapper().TraversalsByName(SliceElemType(val), columns[:flagIdx])
+		if err := missingColumns(columns[:flagIdx], traversals); err != nil {
+			return nil, err
+		}
+	}
+
+	var flags []bool
+	for rows.Next() {
+		var inserted bool
+
+		if dest == nil {
+			values, err := rows.Values()
+			if err != nil {
+				return nil, err
+			}
+
+			inserted, _ = values[flagIdx].(bool)
+		} else {
+			elem := reflect.New(SliceElemType(val))
+
+			values := make([]interface{}, len(columns))
+			values[flagIdx] = &inserted
+
+			if err := fieldsByTraversal(elem, traversals, values[:flagIdx], true); err != nil {
+				return nil, err
+			}
+
+			if err := rows.Scan(values...); err != nil {
+				return nil, err
+			}
+
+			ReflectAppend(val, elem)
+		}
+
+		flags = append(flags, inserted)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return flags, nil
+}
+
+// How an upsert handles rows sharing the same conflict column values
+type DuplicateKeyPolicy int
+
+const (
+	// Send duplicates as is, Postgres rejects them for DO UPDATE
+	DuplicateKeysAllow DuplicateKeyPolicy = iota
+
+	// Fail generation, listing the duplicated rows
+	DuplicateKeysError
+
+	// Keep only the last row for each key
+	DuplicateKeysLastWins
+)
+
+// Check for rows with duplicate OnConflictColumns values before sending the insert
+// Rows with a NULL or DEFAULT key column never conflict, so are never duplicates
+func (ins StructInsert) WithDuplicateKeys(policy DuplicateKeyPolicy) StructInsert {
+	return StructInsert{
+		tableName:    ins.tableName,
+		data:         ins.data,
+		returning:    ins.returning,
+		conflictStmt: ins.conflictStmt,
+		conflict:     ins.conflict,
+		insertedFlag: ins.insertedFlag,
+		duplicates:   policy,
+	}
+}
+
+// Copy of the insert with duplicate keys resolved according to its policy
+func (ins StructInsert) resolveDuplicates(m *reflectx.Mapper) (StructInsert, error) {
+
+	if ins.duplicates == DuplicateKeysAllow || len(ins.data) < 2 {
+		return ins, nil
+	}
+
+	if ins.conflict == nil || len(ins.conflict.columns) == 0 {
+		return ins, errors.New("detecting duplicate keys requires OnConflictColumns")
+	}
+
+	extractor, err := NewStructColumnValueExtractor(m, ins.data[0])
+	if err != nil {
+		return ins, err
+	}
+
+	keys := make([]string, len(ins.data))
+	seen := make(map[string][]int)
+
+	for idx, dat := range ins.data {
+		extracted, err := extractor.Extract(dat)
+		if err != nil {
+			return ins, err
+		}
+
+		key, ok, err := conflictKey(extracted, ins.conflict.columns)
+		if err != nil {
+			return ins, fmt.Errorf("row %d: %w", idx, err)
+		} else if !ok {
+			continue
+		}
+
+		keys[idx] = key
+		seen[key] = append(seen[key], idx)
+	}
+
+	var duplicates []string
+	for idx := range ins.data {
+		if rows := seen[keys[idx]]; len(keys[idx]) > 0 && len(rows) > 1 && rows[0] == idx {
+			rowNums := make([]string, len(rows))
+			for i, row := range rows {
+				rowNums[i] = strconv.Itoa(row)
+			}
+
+			duplicates = append(duplicates, "rows "+strings.Join(rowNums, ", "))
+		}
+	}
+
+	if len(duplicates) == 0 {
+		return ins, nil
+	}
+
+	if ins.duplicates == DuplicateKeysError {
+		return ins, fmt.Errorf("duplicate conflict keys (%s): %s", strings.Join(ins.conflict.columns, ", "), strings.Join(duplicates, "; "))
+	}
+
+	var data []interface{}
+	for idx, dat := range ins.data {
+		if rows := seen[keys[idx]]; len(keys[idx]) == 0 || rows[len(rows)-1] == idx {
+			data = append(data, dat)
+		}
+	}
+
+	return ins.withData(data), nil
+}
+
+// Comparable key of the conflict column values, false if any are NULL or DEFAULT
+// Values are compared by what they hold, so pointers and driver.Valuers with equal values produce the same key
+func conflictKey(extracted ExtractedColumnValues, columns []string) (string, bool, error) {
+
+	values := make([]interface{}, len(columns))
+	for idx, column := range columns {
+		val, ok := extracted.ColumnValue(column)
+		if !ok || val.UseDefault() || val.UseNULL() {
+			return "", false, nil
+		}
+
+		key, err := keyValue(val.Value())
+		if err != nil {
+			return "", false, fmt.Errorf("conflict column %s: %w", column, err)
+		} else if key == nil {
+			return "", false, nil
+		}
+
+		values[idx] = key
+	}
+
+	return fmt.Sprintf("%#v", values), true, nil
+}
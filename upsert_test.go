@@ -0,0 +1,141 @@
+package pgxload
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willtrking/pgxload/pgxloadtest"
+)
+
+type upsertRow struct {
+	ID    int64 `pgxload:"defaultZero"`
+	Email string
+	Name  string
+	Age   int `pgxload:"omitZero"`
+}
+
+func TestStructInsert_OnConflict(t *testing.T) {
+
+	m := DefaultConfig.generateMapper()
+	ins := NewStructInsert("people", &upsertRow{Email: "a@b.c", Name: "a", Age: 3}, &upsertRow{Email: "d@e.f", Name: "d"})
+
+	stmt, _, err := ins.OnConflictColumns("email").DoUpdateAll().WithReturningColumns("id").GenerateInsert(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `INSERT INTO people ("id", "email", "name", "age") VALUES (DEFAULT, $1, $2, $3), (DEFAULT, $4, $5, DEFAULT) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name", "age" = EXCLUDED."age" RETURNING "id"`, stmt)
+	}
+
+	stmt, _, err = ins.OnConflictConstraint("people_email_key").Where("people.name <> EXCLUDED.name").DoUpdateColumns("name").GenerateInsert(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `INSERT INTO people ("id", "email", "name", "age") VALUES (DEFAULT, $1, $2, $3), (DEFAULT, $4, $5, DEFAULT) ON CONFLICT ON CONSTRAINT "people_email_key" DO UPDATE SET "name" = EXCLUDED."name" WHERE people.name <> EXCLUDED.name`, stmt)
+	}
+
+	stmt, _, err = ins.OnConflictColumns().DoNothing().GenerateInsert(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `INSERT INTO people ("id", "email", "name", "age") VALUES (DEFAULT, $1, $2, $3), (DEFAULT, $4, $5, DEFAULT) ON CONFLICT DO NOTHING`, stmt)
+	}
+
+	_, _, err = ins.OnConflictColumns("email").DoUpdateAll().GenerateUnnestInsert(m)
+	assert.Error(t, err)
+
+	stmt, _, err = NewStructInsert("people", &upsertRow{Email: "a@b.c", Name: "a"}).OnConflictColumns("email").DoUpdateAll().GenerateUnnestInsert(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `INSERT INTO people ("email", "name") SELECT * FROM unnest($1::text[], $2::text[]) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`, stmt)
+	}
+
+	_, _, err = ins.OnConflictColumns().DoUpdateAll().GenerateInsert(m)
+	assert.Error(t, err)
+
+	_, _, err = ins.OnConflictColumns("email").Where("people.name <> EXCLUDED.name").DoNothing().GenerateInsert(m)
+	assert.EqualError(t, err, "ON CONFLICT DO NOTHING can't have a Where, it only applies to DO UPDATE")
+
+	_, _, err = NewStructInsert("people", &upsertRow{Email: "a@b.c"}).OnConflictColumns("email", "name").DoUpdateAll().GenerateInsert(m)
+	assert.Error(t, err)
+}
+
+func TestExecStructUpsert(t *testing.T) {
+
+	ctx := context.Background()
+
+	conn := pgxloadtest.NewConn()
+	loader, _ := NewPgxLoader(conn)
+
+	ins := NewStructInsert("people", &upsertRow{Email: "a@b.c", Name: "a"}, &upsertRow{Email: "d@e.f", Name: "d"}).
+		OnConflictColumns("email").DoUpdateAll().
+		WithReturningColumns("id")
+
+	conn.ExpectQuery(`INSERT INTO people ("id", "email", "name") VALUES (DEFAULT, $1, $2), (DEFAULT, $3, $4) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name" RETURNING "id", (xmax = 0) AS "inserted"`).
+		WillReturnRows(pgxloadtest.NewRows("id", "inserted").AddRow(int64(1), false).AddRow(int64(2), true))
+
+	var rows []upsertRow
+	flags, err := ExecStructUpsert(ctx, loader, ins, &rows)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, true}, flags)
+	assert.Equal(t, []upsertRow{{ID: 1}, {ID: 2}}, rows)
+
+	conn.ExpectQueryRegex(`RETURNING "id", \(xmax = 0\) AS "inserted"$`).
+		WillReturnRows(pgxloadtest.NewRows("id", "inserted").AddRow(int64(1), true))
+
+	flags, err = ExecStructUpsert(ctx, loader, ins.WithInsertedFlag(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true}, flags)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+type duplicateKeyRow struct {
+	Email string `pgxload:"nullZero"`
+	Name  string
+}
+
+func TestStructInsert_WithDuplicateKeys(t *testing.T) {
+
+	m := DefaultConfig.generateMapper()
+
+	ins := NewStructInsert("people",
+		&duplicateKeyRow{Email: "a@b.c", Name: "first"},
+		&duplicateKeyRow{Email: "d@e.f", Name: "d"},
+		&duplicateKeyRow{Email: "a@b.c", Name: "last"},
+		&duplicateKeyRow{Name: "no email"},
+		&duplicateKeyRow{Name: "no email"},
+	).OnConflictColumns("email").DoUpdateAll()
+
+	_, _, err := ins.WithDuplicateKeys(DuplicateKeysError).GenerateInsert(m)
+	if assert.Error(t, err) {
+		assert.Equal(t, "duplicate conflict keys (email): rows 0, 2", err.Error())
+	}
+
+	stmt, params, err := ins.WithDuplicateKeys(DuplicateKeysLastWins).GenerateInsert(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `INSERT INTO people ("email", "name") VALUES ($1, $2), ($3, $4), (NULL, $5), (NULL, $6) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`, stmt)
+		assert.Equal(t, []interface{}{"d@e.f", "d", "a@b.c", "last", "no email", "no email"}, params)
+	}
+
+	_, _, err = NewStructInsert("people", &upsertRow{}, &upsertRow{}).WithDuplicateKeys(DuplicateKeysError).GenerateInsert(m)
+	assert.Error(t, err)
+}
+
+type pointerKeyRow struct {
+	Email     *string
+	CreatedAt time.Time
+	Name      string
+}
+
+func TestStructInsert_WithDuplicateKeys_PointerKeys(t *testing.T) {
+
+	m := DefaultConfig.generateMapper()
+
+	first, last := "a@b.c", "a@b.c"
+	created := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	_, _, err := NewStructInsert("people",
+		&pointerKeyRow{Email: &first, CreatedAt: created, Name: "first"},
+		&pointerKeyRow{Email: &last, CreatedAt: created.In(time.FixedZone("UTC+2", 2*60*60)), Name: "last"},
+		&pointerKeyRow{Name: "no email"},
+		&pointerKeyRow{Name: "no email"},
+	).OnConflictColumns("email", "created_at").DoUpdateAll().WithDuplicateKeys(DuplicateKeysError).GenerateInsert(m)
+
+	if assert.Error(t, err) {
+		assert.Equal(t, "duplicate conflict keys (email, created_at): rows 0, 1", err.Error())
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"unicode"
 
 	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
 	"github.com/jmoiron/sqlx/reflectx"
 )
 
@@ -133,18 +134,83 @@ func missingColumns(columnNames []string, traversals [][]int) error {
 // Run the specified function in the given transaction
 // Will automatically rollback if the function returns an error, and commit if it does not
 // Will rollback transaction in case of panic.
-func RunInTransaction(ctx context.Context, loader PgxLoader, fn func(ctx context.Context, tx PgxTxLoader) error) error {
+// Any role or local settings in config are SET LOCAL before fn is called
+// If loader was resolved from a context holding a transaction, a savepoint within that transaction is used instead
+// If config specifies a retry policy, the whole transaction is re-run on retryable errors
+func RunInTransaction(ctx context.Context, loader PgxLoader, fn func(ctx context.Context, tx PgxTxLoader) error, config ...*TxConfig) error {
 
-	tx, err := loader.Begin(ctx)
+	return RunInTransactionWithOptions(ctx, loader, pgx.TxOptions{}, fn, config...)
+}
+
+// Same as RunInTransaction, but begins the transaction with the specified isolation level, access and deferrable modes
+func RunInTransactionWithOptions(ctx context.Context, loader PgxLoader, txOptions pgx.TxOptions, fn func(ctx context.Context, tx PgxTxLoader) error, config ...*TxConfig) error {
+
+	cfg, err := resolveTxConfig(config)
 	if err != nil {
 		return err
 	}
 
-	return internalRunInTransaction(ctx, NewPgxTxLoader(loader, tx), fn)
+	if joined, ok := loader.(*joinedTxLoader); ok {
+		if txOptions != (pgx.TxOptions{}) {
+			return errJoinedTxOptions
+		}
+
+		return RunInNestedTransaction(ctx, joined.PgxTxLoader, fn, cfg)
+	}
+
+	return cfg.Retry.run(ctx, func() error {
+		tx, err := loader.BeginTx(ctx, txOptions)
+		if err != nil {
+			return err
+		}
+
+		txLoader := NewPgxTxLoader(loader, tx)
+		if err := cfg.applyLocal(ctx, txLoader); err != nil {
+			_ = txLoader.Rollback(ctx)
+			return err
+		}
+
+		return internalRunInTransaction(ctx, txLoader, fn)
+	})
+}
+
+// Run the specified function in a transaction which nests inside any transaction loader is already part of
+// If loader is a PgxTxLoader, a savepoint is created which is rolled back to on error or panic, and released on success
+// Otherwise behaves the same as RunInTransaction. The retry policy in config only applies to new transactions,
+// local settings in a savepoint are reverted if it rolls back
+func RunInNestedTransaction(ctx context.Context, loader QueryLoader, fn func(ctx context.Context, tx PgxTxLoader) error, config ...*TxConfig) error {
+
+	switch l := loader.(type) {
+	case PgxTxLoader:
+		cfg, err := resolveTxConfig(config)
+		if err != nil {
+			return err
+		}
+
+		savepoint, err := l.Begin(ctx)
+		if err != nil {
+			return err
+		}
+
+		txLoader := newNestedPgxTxLoader(l, savepoint)
+		if err := cfg.applyLocal(ctx, txLoader); err != nil {
+			_ = txLoader.Rollback(ctx)
+			return err
+		}
+
+		return internalRunInTransaction(ctx, txLoader, fn)
+	case PgxLoader:
+		return RunInTransaction(ctx, l, fn, config...)
+	}
+
+	return errors.New("loader must be a PgxLoader or PgxTxLoader")
 }
 
 // Internal func used by RunInTransaction. Will rollback transaction in case of panic.
+// The context passed to fn carries tx, see LoaderFromContext. Commit, rollback and their callbacks get the
+// original context, so work done after the transaction ends doesn't join the closed transaction
 func internalRunInTransaction(ctx context.Context, tx PgxTxLoader, fn func(ctx context.Context, tx PgxTxLoader) error) error {
+
 	defer func() {
 		if err := recover(); err != nil {
 			_ = tx.Rollback(ctx)
@@ -152,7 +218,7 @@ func internalRunInTransaction(ctx context.Context, tx PgxTxLoader, fn func(ctx c
 		}
 	}()
 
-	if err := fn(ctx, tx); err != nil {
+	if err := fn(WithLoader(ctx, tx), tx); err != nil {
 		_ = tx.Rollback(ctx)
 		return err
 	}
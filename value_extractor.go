@@ -85,7 +85,7 @@ func (s StructColumnValueExtractor) ExtractRawColumnData(data interface{}, colum
 
 	for _, field := range s.structMap.Index {
 		if field.Name == column {
-			dataField := reflectx.FieldByIndexes(val, field.Index)
+			dataField := reflectx.FieldByIndexesReadOnly(val, field.Index)
 
 			return dataField.Interface(), nil
 
@@ -95,6 +95,92 @@ func (s StructColumnValueExtractor) ExtractRawColumnData(data interface{}, colum
 	return nil, errors.New("unknown column " + column)
 }
 
+// Extract the value of the field tagged with shardKey
+func (s StructColumnValueExtractor) ExtractShardKey(data interface{}) (interface{}, error) {
+	val := reflect.ValueOf(data)
+
+	for fieldNum, field := range s.structMap.Index {
+		if s.tagOpts(fieldNum).ShardKey {
+			return reflectx.FieldByIndexesReadOnly(val, field.Index).Interface(), nil
+		}
+	}
+
+	return nil, errors.New("missing field tagged with shardKey")
+}
+
+// Columns present in any of the extracted rows, in struct field order
+func (s StructColumnValueExtractor) unionColumns(rows []ExtractedColumnValues) []string {
+
+	present := make(map[string]bool)
+	for _, row := range rows {
+		for _, column := range row.columns {
+			present[column] = true
+		}
+	}
+
+	var columns []string
+	for _, field := range s.structMap.Index {
+		if present[field.Name] {
+			columns = append(columns, field.Name)
+			delete(present, field.Name)
+		}
+	}
+
+	return columns
+}
+
+// Columns of the fields tagged with pk, in struct field order
+func (s StructColumnValueExtractor) PrimaryKeyColumns() []string {
+
+	var columns []string
+	for fieldNum, field := range s.structMap.Index {
+		if s.tagOpts(fieldNum).PrimaryKey && field.Parent == s.structMap.Tree {
+			columns = append(columns, field.Name)
+		}
+	}
+
+	return columns
+}
+
+// Extract the values of the fields tagged with pk, in the order of PrimaryKeyColumns
+func (s StructColumnValueExtractor) ExtractPrimaryKey(data interface{}) ([]string, []interface{}, error) {
+
+	columns := s.PrimaryKeyColumns()
+	if len(columns) == 0 {
+		return nil, nil, errors.New("missing field tagged with pk")
+	}
+
+	values := make([]interface{}, len(columns))
+	for idx, column := range columns {
+		val, err := s.ExtractRawColumnData(data, column)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if isNilValue(val) {
+			return nil, nil, errors.New("missing value for pk column " + column)
+		}
+
+		values[idx] = val
+	}
+
+	return columns, values, nil
+}
+
+// Columns of every top level field which can be written, excluding omit tagged fields and omitted columns
+// omitZero fields are included, as whether they're written depends on their value
+func (s StructColumnValueExtractor) WritableColumns() []string {
+
+	var columns []string
+	for fieldNum := range s.structMap.Index {
+		if !s.omitField(fieldNum, false) {
+			columns = append(columns, s.structMap.Index[fieldNum].Name)
+		}
+	}
+
+	return columns
+}
+
 func (s StructColumnValueExtractor) Extract(data interface{}) (ExtractedColumnValues, error) {
 	var columns []string
 
@@ -144,6 +230,36 @@ func (s StructColumnValueExtractor) Extract(data interface{}) (ExtractedColumnVa
 	}, nil
 }
 
+// Columns of the extracted values, in struct field order
+func (e ExtractedColumnValues) Columns() []string {
+	return e.columns
+}
+
+// The value extracted for column, and whether the column was extracted
+func (e ExtractedColumnValues) ColumnValue(column string) (ColumnValue, bool) {
+	val, ok := e.columnValues[column]
+	return val, ok
+}
+
+// Copy of the extracted values using exactly columns, with DEFAULT for any column which wasn't extracted
+func (e ExtractedColumnValues) withColumns(columns []string) ExtractedColumnValues {
+
+	values := make(map[string]ColumnValue, len(columns))
+	for _, column := range columns {
+		val, ok := e.columnValues[column]
+		if !ok {
+			val = ColumnValue{value: nil, sqlDefault: true}
+		}
+
+		values[column] = val
+	}
+
+	return ExtractedColumnValues{
+		columns:      columns,
+		columnValues: values,
+	}
+}
+
 type ColumnValue struct {
 	value      interface{}
 	sqlDefault bool